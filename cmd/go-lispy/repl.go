@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/LPLemnij/go-lispy/lispy"
+	"github.com/peterh/liner"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the repl logic/entry point of the program. It takes text you write in //
+// the command line and hands it to an interpreter from the lispy package, which does all  //
+// of the actual parsing and evaluating.                                                    //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+var treeWalk = flag.Bool("treewalk", false, "evaluate by walking the code instead of compiling it")
+
+// The characters symbols are made of, for finding the word to complete
+const symbolChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789+-*/_=<>!&?"
+
+func main() {
+	flag.Parse()
+	os.Exit(repl())
+}
+
+// Run the repl until the input runs out or os/exit is called, giving back the exit code. This
+// is kept apart from main so that the deferred cleanup runs before the program exits.
+func repl() int {
+	interp := lispy.New()
+	interp.TreeWalk = *treeWalk
+	interp.EnableExit()
+
+	//The line editor gives arrow keys, emacs style editing keys and history
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(func(text string, pos int) (string, []string, string) {
+		return completeSymbol(interp, text, pos)
+	})
+
+	interp.Debugger = chooseRestart(line, interp)
+
+	history := historyPath()
+	if f, err := os.Open(history); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+	defer saveHistory(line, history)
+
+	fmt.Println("My Go Lisp v1")
+
+	for {
+		//Read until every bracket is closed, so definitions can span several lines
+		fmt.Print("\n")
+		text, done := readForms(line)
+		if done && strings.TrimSpace(text) == "" {
+			fmt.Println()
+			return 0
+		}
+
+		if strings.TrimSpace(text) != "" {
+			line.AppendHistory(strings.Replace(strings.TrimSpace(text), "\n", " ", -1))
+		}
+
+		//Eval and Print each top level expression in order, the same way load does for files.
+		//Parse errors only lose what was just typed, everything defined so far stays.
+		printed := 0
+		exit := -1
+		err := interp.EvalEach(text, func(x lispy.Value, err error) bool {
+			lerr, isErr := err.(*lispy.Error)
+			if isErr {
+				if code, ok := lerr.ExitCode(); ok {
+					exit = code
+					return false
+				}
+			}
+
+			if printed > 0 {
+				fmt.Println()
+			}
+			printed++
+
+			if isErr {
+				fmt.Print(lerr, lerr.StackTrace())
+			} else if err != nil {
+				fmt.Print(err)
+			} else {
+				fmt.Print(lispy.Sprint(x))
+			}
+
+			return true
+		})
+
+		if exit >= 0 {
+			fmt.Println()
+			return exit
+		}
+
+		if err != nil {
+			if printed > 0 {
+				fmt.Println()
+			}
+			fmt.Print(err)
+		}
+
+		if done {
+			fmt.Println()
+			return 0
+		}
+	}
+}
+
+// Read lines until the brackets in them balance, showing a continuation prompt for each line
+// after the first. Reports true once there is no more input, because of Ctrl-D or the end of
+// piped input. Ctrl-C throws away whatever has been typed so far.
+func readForms(line *liner.State) (string, bool) {
+	text := ""
+	prompt := "Go-Lispy>"
+
+	for {
+		l, err := line.Prompt(prompt)
+
+		if err == liner.ErrPromptAborted {
+			text = ""
+			prompt = "Go-Lispy>"
+			fmt.Println()
+			continue
+		} else if err != nil {
+			if err != io.EOF {
+				fmt.Println(err)
+			}
+			return text, true
+		}
+
+		text += l + "\n"
+
+		if !lispy.Incomplete(text) {
+			return text, false
+		}
+
+		prompt = "     ...>"
+	}
+}
+
+// Let the user pick a restart when an error is thrown that nothing catches. Any values the
+// restart needs are read and evaluated like normal input.
+func chooseRestart(line *liner.State, interp *lispy.Interpreter) func(*lispy.Error, []lispy.Restart) (int, []lispy.Value) {
+	return func(err *lispy.Error, restarts []lispy.Restart) (int, []lispy.Value) {
+		fmt.Println()
+		fmt.Println(err.Type + ": " + err.Message)
+		fmt.Println("Restarts:")
+		for i := 0; i < len(restarts); i++ {
+			fmt.Println("  " + strconv.Itoa(i) + ": " + strings.Join(append([]string{restarts[i].Name}, restarts[i].Formals...), " "))
+		}
+		fmt.Println("  " + strconv.Itoa(len(restarts)) + ": let the error through")
+
+		text, readErr := line.Prompt("Restart>")
+		n, convErr := strconv.Atoi(strings.TrimSpace(text))
+		if readErr != nil || convErr != nil || n < 0 || n >= len(restarts) {
+			return -1, nil
+		}
+
+		args := []lispy.Value{}
+		for i := 0; i < len(restarts[n].Formals); i++ {
+			name := restarts[n].Formals[i]
+			if name == "&" {
+				continue
+			}
+
+			text, readErr := line.Prompt(name + ">")
+			if readErr != nil {
+				return -1, nil
+			}
+
+			v, evalErr := interp.EvalString(text)
+			if evalErr != nil {
+				fmt.Println(evalErr)
+				return -1, nil
+			}
+			args = append(args, v)
+		}
+
+		return n, args
+	}
+}
+
+// Complete the symbol the cursor is at the end of with anything defined that starts with it.
+// liner gives pos in runes rather than bytes, so the line is worked on as runes.
+func completeSymbol(interp *lispy.Interpreter, text string, pos int) (string, []string, string) {
+	line := []rune(text)
+
+	start := pos
+	for start > 0 && strings.ContainsRune(symbolChars, line[start-1]) {
+		start--
+	}
+
+	prefix := string(line[start:pos])
+	if prefix == "" {
+		return string(line[:pos]), nil, string(line[pos:])
+	}
+
+	matches := []string{}
+	symbols := interp.Symbols()
+	for i := 0; i < len(symbols); i++ {
+		if strings.HasPrefix(symbols[i], prefix) {
+			matches = append(matches, symbols[i])
+		}
+	}
+
+	return string(line[:start]), matches, string(line[pos:])
+}
+
+// History is kept in the user's home directory so it lasts between sessions
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".go-lispy_history"
+	}
+
+	return filepath.Join(home, ".go-lispy_history")
+}
+
+func saveHistory(line *liner.State, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	line.WriteHistory(f)
+}
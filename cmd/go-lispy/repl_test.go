@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LPLemnij/go-lispy/lispy"
+)
+
+// The tests run the repl in a copy of the test binary, since it reads from stdin and writes to
+// stdout, and ends the program when it is done
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_LISPY_REPL") == "1" {
+		main()
+	}
+
+	os.Exit(m.Run())
+}
+
+// Feed input to the repl, giving back what it printed and the code it exited with
+func runRepl(t *testing.T, input string) (string, int) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "GO_LISPY_REPL=1", "HOME="+t.TempDir())
+	cmd.Stdin = strings.NewReader(input)
+
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return string(out), exit.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out), 0
+}
+
+func TestReplSurvivesParseErrors(t *testing.T) {
+	out, code := runRepl(t, "(def {x} 1)\n(+ 1 1.2.3)\n(+ x 1\n)")
+
+	//The definition before the error is still there afterwards, and the end of the input ends the
+	//repl cleanly
+	want := "My Go Lisp v1\n\nGo-Lispy>()\n" +
+		"Go-Lispy>Parse error on line 1, column 6: malformed number \"1.2.3\"\n" +
+		"Go-Lispy>     ...>2\n" +
+		"Go-Lispy>\n"
+	if code != 0 || out != want {
+		t.Errorf("exited with %d after printing %q", code, out)
+	}
+}
+
+func TestReplReadsUntilBracketsBalance(t *testing.T) {
+	out, code := runRepl(t, "(def {f} (fn {x}\n  {list x \")\"}))\n(f 1) (f 2)\n\"a\nb\"\n")
+
+	want := "My Go Lisp v1\n\nGo-Lispy>     ...>()\n" +
+		"Go-Lispy>{1 \")\"}\n{2 \")\"}\n" +
+		"Go-Lispy>     ...>\"a\\nb\"\n" +
+		"Go-Lispy>\n"
+	if code != 0 || out != want {
+		t.Errorf("exited with %d after printing %q", code, out)
+	}
+}
+
+func TestReplExitCode(t *testing.T) {
+	//An error thrown with the type "exit" is printed like any other instead of quitting
+	out, code := runRepl(t, "(throw \"exit\" \"forged\" 7)\n(try {os/exit 3} {catch {e} {0}} {finally {print \"bye\"}})\n(print \"not reached\")\n")
+
+	if code != 3 || !strings.Contains(out, "forged") || !strings.Contains(out, "bye") || strings.Contains(out, "not reached") {
+		t.Errorf("exited with %d after printing %q", code, out)
+	}
+}
+
+func TestCompleteSymbolCountsRunes(t *testing.T) {
+	interp := lispy.New()
+
+	//The cursor is after (list "é" hea, which is 13 runes but 14 bytes
+	head, matches, tail := completeSymbol(interp, `(list "é" hea) "ü"`, 13)
+
+	if head != `(list "é" ` || tail != `) "ü"` || !reflect.DeepEqual(matches, []string{"head"}) {
+		t.Errorf("got %q %q %q", head, matches, tail)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/LPLemnij/go-lispy
+
+go 1.21
+
+require (
+	github.com/alecthomas/participle v0.4.1
+	github.com/peterh/liner v1.2.2
+)
+
+require github.com/mattn/go-runewidth v0.0.3 // indirect
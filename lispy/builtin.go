@@ -0,0 +1,427 @@
+package lispy
+
+import (
+	"fmt"
+	"os"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the suite of builtin functions that my go version of lisp comes with. //
+// Any builtin functions can be added by following the LBuiltin construct and adding them   //
+// to the global environment in lenv.go														//
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+type LBuiltin func(*LEnv, *LVal) *LVal
+
+func builtinHead(e *LEnv, a *LVal) *LVal {
+	//There may have been more than one list in the lval or something
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'head' was given too many arguments")
+	}
+
+	//Not a q expression
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'head' must be given a q expression as an argument")
+	}
+
+	//Head was passed in an empty list
+	if len(a.Cell[0].Cell) == 0 {
+		return lvalErr("Function 'head' passed in an empty q expression")
+	}
+
+	//Build a list holding just the first element(which is the head) instead of cutting down the one passed in
+	v := lvalQexpr()
+	v.Cell = append(v.Cell, a.Cell[0].Cell[0])
+
+	return v
+}
+
+func builtinLambda(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 {
+		return lvalErr("Lambda was given an improper number of arguments")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("First argument was not a q expression")
+	}
+
+	if a.Cell[1].Type != LVAL_QEXPR {
+		return lvalErr("Second argument was not a q expression")
+	}
+
+	for i := 0; i < len(a.Cell[0].Cell); i++ {
+		if a.Cell[0].Cell[i].Type != LVAL_SYM {
+			return lvalErr("First argument must contain list of symbols")
+		}
+	}
+
+	formals := lvalPop(a, 0)
+	body := lvalPop(a, 0)
+
+	return lvalLambda(e, formals, body)
+}
+
+func builtinDefMacro(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 3 {
+		return lvalErr("Function 'defmacro' must be passed a name, a list of formals, and a body")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR || len(a.Cell[0].Cell) != 1 || a.Cell[0].Cell[0].Type != LVAL_SYM {
+		return lvalErr("Function 'defmacro' must be given a q expression holding a single symbol as its name")
+	}
+
+	name := lvalPop(a, 0).Cell[0]
+
+	//A macro is just a lambda that gets its arguments unevaluated, so let lambda do the checking
+	f := builtinLambda(e, a)
+	if f.Type == LVAL_ERR {
+		return f
+	}
+
+	f.Macro = true
+	lenvDef(e, name, f)
+
+	return lvalSexpr()
+}
+
+func builtinMacroExpand(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'macroexpand' given too many arguments")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'macroexpand' must be given a q expression as an argument")
+	}
+
+	x := lvalAs(lvalTake(a, 0), LVAL_SEXPR)
+
+	//Both {unless c a b} and {(unless c a b)} refer to the same code
+	if len(x.Cell) == 1 && x.Cell[0].Type == LVAL_SEXPR {
+		x = x.Cell[0]
+	}
+
+	//Keep expanding for as long as the head of the code is a macro
+	for x.Type == LVAL_SEXPR && len(x.Cell) > 0 && x.Cell[0].Type == LVAL_SYM {
+		f := lenvGet(e, x.Cell[0])
+		if f.Type != LVAL_FUN || !f.Macro {
+			break
+		}
+
+		args := lvalSexpr()
+		args.Cell = append(args.Cell, x.Cell[1:]...)
+		x = lvalMacroExpand(e, f, args)
+	}
+
+	if x.Type == LVAL_SEXPR {
+		x = lvalAs(x, LVAL_QEXPR)
+	}
+
+	return x
+}
+
+// (quote {x}) gives back x without evaluating it, with an s expression turned into a q expression
+// so that '(1 2) is the same as {1 2}. 'x reads as a call to it.
+func builtinQuote(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_QEXPR || len(a.Cell[0].Cell) != 1 {
+		return lvalErr("Function 'quote' must be given a q expression holding a single expression")
+	}
+
+	x := a.Cell[0].Cell[0]
+	if x.Type == LVAL_SEXPR {
+		return lvalAs(x, LVAL_QEXPR)
+	}
+
+	return x
+}
+
+func builtinQuasiquote(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'quasiquote' given too many arguments")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'quasiquote' must be given a q expression as an argument")
+	}
+
+	return lvalQuasiquote(e, lvalTake(a, 0), 1)
+}
+
+func builtinUnquote(e *LEnv, a *LVal) *LVal {
+	return lvalErr("unquote can only be used inside of a quasiquote")
+}
+
+func builtinUnquoteSplicing(e *LEnv, a *LVal) *LVal {
+	return lvalErr("unquote-splicing can only be used inside of a quasiquote")
+}
+
+func builtinTail(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'tail' was given too many arguments")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'tail' must be given a q expression as an argument")
+	}
+
+	if len(a.Cell[0].Cell) == 0 {
+		return lvalErr("Function 'tail' passed in an empty q expression")
+	}
+
+	//The tail shares its cells with the list it came from
+	v := lvalAs(lvalTake(a, 0), LVAL_QEXPR)
+	v.Cell = v.Cell[1:]
+	return v
+}
+
+func builtinList(e *LEnv, a *LVal) *LVal {
+	a.Type = LVAL_QEXPR
+	return a
+}
+
+func builtinEval(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'eval' given too many arguments")
+	}
+
+	if a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("function 'eval' must be given a q expression as an argument")
+	}
+
+	x := lvalAs(lvalTake(a, 0), LVAL_SEXPR)
+
+	return lvalTail(e, x)
+}
+
+// (apply f {args}) calls f with the elements of args as its arguments. Since (f) on its own
+// gives back f, this is how a function that takes no arguments is called, as in (apply f {}).
+func builtinApply(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 || a.Cell[0].Type != LVAL_FUN || a.Cell[1].Type != LVAL_QEXPR {
+		return lvalErr("Function 'apply' must be given a function and a q expression of arguments")
+	}
+
+	if a.Cell[0].Macro {
+		return lvalErr("Function 'apply' cannot be given a macro")
+	}
+
+	//The call binds its arguments by popping them, so it gets its own list rather than the q expression
+	args := lvalSexpr()
+	args.Cell = append(args.Cell, a.Cell[1].Cell...)
+
+	return lvalCall(e, a.Cell[0], args)
+}
+
+func builtinJoin(e *LEnv, a *LVal) *LVal {
+	for i := 0; i < len(a.Cell); i++ {
+		if a.Cell[i].Type != LVAL_QEXPR {
+			return lvalErr("One of the arguments to join was not a q expression")
+		}
+	}
+
+	x := lvalQexpr()
+	for len(a.Cell) > 0 {
+		x.Cell = append(x.Cell, lvalPop(a, 0).Cell...)
+	}
+	return x
+}
+
+func builtinPrint(e *LEnv, a *LVal) *LVal {
+	printLVal(a)
+
+	return lvalSexpr()
+}
+
+func builtinVar(e *LEnv, a *LVal, op string) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function " + op + " must be given a q expression of symbols")
+	}
+
+	//When using def, we make sure that the first parameter is a list of symbols
+	syms := a.Cell[0]
+	for i := 0; i < len(syms.Cell); i++ {
+		if syms.Cell[i].Type != LVAL_SYM {
+			return lvalErr("Function def cannot define a non symbol")
+		}
+	}
+
+	//Check if the lists match
+	if len(syms.Cell) != len(a.Cell)-1 {
+		return lvalErr("The symbol list and the value list are different lengths")
+	}
+
+	for i := 0; i < len(syms.Cell); i++ {
+		if op == "def" {
+			lenvDef(e, syms.Cell[i], a.Cell[i+1])
+		}
+
+		if op == "=" {
+			lenvPut(e, syms.Cell[i], a.Cell[i+1])
+		}
+	}
+
+	return lvalSexpr()
+}
+
+func builtinDef(e *LEnv, a *LVal) *LVal {
+	return builtinVar(e, a, "def")
+}
+
+func builtinPut(e *LEnv, a *LVal) *LVal {
+	return builtinVar(e, a, "=")
+}
+
+func builtinAdd(e *LEnv, a *LVal) *LVal {
+	return builtinOp(e, a, "+")
+}
+
+func builtinSubtract(e *LEnv, a *LVal) *LVal {
+	return builtinOp(e, a, "-")
+}
+
+func builtinMultiply(e *LEnv, a *LVal) *LVal {
+	return builtinOp(e, a, "*")
+}
+
+func builtinDivide(e *LEnv, a *LVal) *LVal {
+	return builtinOp(e, a, "/")
+}
+
+func builtinLessThan(e *LEnv, a *LVal) *LVal {
+	return builtinCond(e, a, "<")
+}
+
+func builtinGreaterThan(e *LEnv, a *LVal) *LVal {
+	return builtinCond(e, a, ">")
+}
+
+func builtinGreaterThanOrEqualTo(e *LEnv, a *LVal) *LVal {
+	return builtinCond(e, a, ">=")
+}
+
+func builtinLessThanOrEqualTo(e *LEnv, a *LVal) *LVal {
+	return builtinCond(e, a, "<=")
+}
+
+func builtinEq(e *LEnv, a *LVal) *LVal {
+	return builtinCond(e, a, "==")
+}
+
+func builtinIf(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 3 {
+		return lvalErr("The if condition must be passed in three arguments.")
+	}
+
+	if a.Cell[1].Type != LVAL_QEXPR {
+		return lvalErr("The if condition's second argument must be of type qexpr")
+	}
+
+	if a.Cell[2].Type != LVAL_QEXPR {
+		return lvalErr("The if condition's third argument must be of type qexpr.")
+	}
+
+	var x *LVal
+
+	//The chosen branch is in tail position, so leave evaluating it to lvalEval
+	if !lvalTruthy(a.Cell[0]) {
+		x = lvalTail(e, lvalAs(a.Cell[2], LVAL_SEXPR))
+	} else {
+		x = lvalTail(e, lvalAs(a.Cell[1], LVAL_SEXPR))
+	}
+
+	return x
+}
+
+func builtinCond(e *LEnv, a *LVal, cond string) *LVal {
+	return lvalCompare(a.Cell, cond)
+}
+
+// Compare two arguments without changing them, which lets the vm share this with builtinCond
+func lvalCompare(args []*LVal, cond string) *LVal {
+	if len(args) != 2 {
+		return lvalErr("Function '" + cond + "' must be given two arguments")
+	}
+
+	firstArg := args[0]
+	secondArg := args[1]
+
+	// Equality has to be a different thing since we don't only mess with numbers
+	if cond == "==" {
+		return lvalBool(lvalEq(firstArg, secondArg))
+	}
+
+	if !lvalIsNumber(firstArg) || !lvalIsNumber(secondArg) {
+		return lvalErr("Cannot compare a non number")
+	}
+
+	if !lvalIsReal(firstArg) || !lvalIsReal(secondArg) {
+		return lvalErr("Cannot order complex numbers")
+	}
+
+	return lvalBool(lvalNumCompare(cond, firstArg, secondArg))
+}
+
+func builtinOp(e *LEnv, a *LVal, op string) *LVal {
+	return lvalArith(a.Cell, op)
+}
+
+// Run an operator over the arguments without changing them, which lets the vm share this with builtinOp
+func lvalArith(args []*LVal, op string) *LVal {
+	if len(args) == 0 {
+		return lvalErr("Function '" + op + "' must be given at least one argument")
+	}
+
+	// Make sure all arguments are numbers so we can eval
+	for i := 0; i < len(args); i++ {
+		if !lvalIsNumber(args[i]) {
+			return lvalErr("Cannot operate on a non number")
+		}
+	}
+
+	if op == "-" && len(args) == 1 {
+		return lvalNumNegate(args[0])
+	}
+
+	//Each step makes a new number since the arguments may be shared with an environment
+	x := args[0]
+	for i := 1; i < len(args); i++ {
+		x = lvalNumOp(op, x, args[i])
+		if x.Type == LVAL_ERR {
+			return x
+		}
+	}
+
+	return x
+}
+
+func builtinLoad(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_STR {
+		return lvalErr("Function 'load' must be given a file name string")
+	}
+
+	root, err := lispyParseFile(a.Cell[0].String)
+
+	if err != nil {
+		return lvalErr(err.Error())
+	}
+
+	//Each expression is read just before it is evaluated, so it can use reader macros the ones before it registered
+	for i := 0; i < len(root.Expressions); i++ {
+		v, err := lispyRead(e, root.Expressions[i])
+		if err != nil {
+			return lvalErr(err.Error())
+		}
+
+		if v == nil {
+			continue
+		}
+
+		x := lvalEval(e, v)
+
+		if x.Type == LVAL_ERR {
+			printLVal(x)
+			fprintTrace(os.Stdout, x.Trace)
+			fmt.Println()
+		}
+	}
+
+	return lvalSexpr()
+}
@@ -0,0 +1,80 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestBooleans(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list true false nil)`, "{true false nil}"},
+		{`(list (if 2 {1} {2}) (if 0 {1} {2}) (if nil {1} {2}) (if false {1} {2}) (if {} {1} {2}) (if "" {1} {2}))`, "{1 1 2 2 1 1}"},
+		{`(list (< 1 2) (== nil nil) (== false nil) (== true (> 2 1)) (== 1 true))`, "{true true false true false}"},
+		{`{true false nil}`, "{true false nil}"},
+		{`(def {nil} 1)`, "error: Function def cannot define a non symbol"},
+		{`(def {true} 1)`, "error: Function def cannot define a non symbol"},
+		{`(if 1 {1})`, "error: The if condition must be passed in three arguments."},
+	})
+}
+
+func TestMacros(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{"(defmacro {unless} {c a b} {`(if ,c {,b} {,a})}) (list (unless (> 1 2) 1 2) (macroexpand {unless (> x 1) a b}))", "{1 {if (> x 1) {b} {a}}}"},
+		{"(defmacro {my-or} {a b} {`((fn {t} {if t {t} {,b}}) ,a)}) (list (my-or false 5) (my-or 3 5))", "{5 3}"},
+		{"(defmacro {twice} {x} {`(list ,x ,x)}) (twice (+ 1 1))", "{2 2}"},
+		{"(def {x} 5) `(x ,x ,@{1 2} (,x))", "{x 5 1 2 (5)}"},
+		{"(def {x} 5) (list ``(a ,,(+ 1 2)) ``(a ,x ,,x) `(1 `(2 ,(3 ,x))) ```x `,x)", "{{quasiquote {a (unquote 3)}} {quasiquote {a (unquote x) (unquote 5)}} {1 (quasiquote {2 (unquote (3 5))})} {quasiquote {quasiquote {x}}} 5}"},
+		{"(defmacro {m} {v} {``(list ,,v)}) (list (m 1) (macroexpand {m 2}))", "{{list 1} {quasiquote {list (unquote 2)}}}"},
+		{"`,@{1}", "error: unquote-splicing can only be used inside of a list"},
+		{`(macroexpand {head {1}})`, "{head {1}}"},
+		{`(defmacro {m} {a b} {a}) (m 1)`, "error: Macro was not passed enough arguments"},
+		{`(macroexpand 1)`, "error: Function 'macroexpand' must be given a q expression as an argument"},
+		{`(defmacro {bad} {x} {+ x {}}) (bad 1)`, "error: Cannot operate on a non number"},
+	})
+}
+
+func TestClosures(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(def {make-adder} (fn {n} {fn {x} {+ x n}})) (def {add2} (make-adder 2)) (def {add5} (make-adder 5)) (list (add2 1) (add5 1) (add2 10))`, "{3 6 12}"},
+		{`(def {f} (fn {x} {fn {y} {list x y}})) (def {g} (f 1)) (def {x} 9) (list (g 2) ((f 3) 4))`, "{{1 2} {3 4}}"},
+		{`(def {f} (fn {x} {(fn {y} {(fn {z} {list x y z})})})) (((f 1) 2) 3)`, "{1 2 3}"},
+		{`(def {add} (fn {x y} {+ x y})) (def {inc} (add 1)) (list (inc 1) (inc 41))`, "{2 42}"},
+		{`(def {f} (fn {x} {fn {x} {x}})) ((f 1) 2)`, "2"},
+
+		//Functions see the scope they were made in, not the one they are called from
+		{`(def {n} 100) (def {f} (fn {_} {n})) (def {g} (fn {n} {f {}})) (list (f {}) (g 1))`, "{100 100}"},
+		{`(def {x} 1) (def {f} (fn {_} {x})) (def {x} 2) (f {})`, "2"},
+		{`(def {f} (fn {x} {= {y} x})) (f 1) y`, "error: Unbound Symbol"},
+	})
+}
+
+func TestBuiltinsDontChangeTheirArguments(t *testing.T) {
+	//Values are shared rather than copied when they are read, so nothing may change them in place
+	runLispyTests(t, []lispyTest{
+		{`(def {xs} {1 2 3}) (list (head xs) (tail xs) (join xs {4}) (join xs xs) (eval (join {list} xs)) xs)`, "{{1} {2 3} {1 2 3 4} {1 2 3 1 2 3} {1 2 3} {1 2 3}}"},
+		{`(def {xs} {1 (+ 2 3) {4}}) (def {ys} xs) (list (tail (tail ys)) (eval (head (tail xs))) (eval (head (tail xs))) xs ys)`, "{{{4}} 5 5 {1 (+ 2 3) {4}} {1 (+ 2 3) {4}}}"},
+		{`(def {xs} {1 2}) (def {f} (fn {ys} {join ys {3}})) (list (f xs) (f xs) xs)`, "{{1 2 3} {1 2 3} {1 2}}"},
+		{`(def {f} (fn {x y} {list x y})) (def {g} (f 1)) (list (g 2) (g 3) (f 4 5))`, "{{1 2} {1 3} {4 5}}"},
+		{`(def {q} {+ 1 2}) (list (eval q) (eval q) q)`, "{3 3 {+ 1 2}}"},
+		{`(def {m} {a b}) (list (macroexpand {head m}) m)`, "{{head m} {a b}}"},
+	})
+}
+
+func TestApply(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list (apply + {1 2 3}) (apply list {}) (apply head {{1 2}}))`, "{6 {} {1}}"},
+		{`(def {f} (fn {} {"called"})) (list (apply f {}) (f))`, `{"called" (\ {} {"called"})}`},
+		{`(def {add} (fn {x y} {+ x y})) (def {xs} {1 2}) (list (apply add xs) ((apply add {10}) 5) xs)`, "{3 15 {1 2}}"},
+		{`(def {loop} (fn {n} {if (== n 0) {0} {apply loop (list (- n 1))}})) (loop 1000)`, "0"},
+		{`(apply + {})`, "error: Function '+' must be given at least one argument"},
+		{`(apply 1 {})`, "error: Function 'apply' must be given a function and a q expression of arguments"},
+		{`(defmacro {m} {x} {x}) (apply m {1})`, "error: Function 'apply' cannot be given a macro"},
+	})
+}
+
+func TestDefErrors(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(def 1 2)`, "error: Function def must be given a q expression of symbols"},
+		{`(= {x})`, "error: The symbol list and the value list are different lengths"},
+		{`(apply def {})`, "error: Function def must be given a q expression of symbols"},
+	})
+}
@@ -0,0 +1,157 @@
+package lispy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains characters, which are a single unicode code point. They are written  //
+// #\a, by name like #\newline or #\space, or by code point in hex like #\x3bb. Strings can //
+// be turned into a q expression of characters and back again to work through them one at //
+// a time.                                                                                  //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Characters that are written by name, since they can't be seen
+var lvalCharNames = map[string]rune{
+	"nul":       0,
+	"alarm":     7,
+	"backspace": 8,
+	"tab":       '\t',
+	"newline":   '\n',
+	"return":    '\r',
+	"escape":    27,
+	"space":     ' ',
+	"delete":    127,
+}
+
+func lvalChar(r rune) *LVal {
+	val := LVal{Type: LVAL_CHAR, Char: r}
+	return &val
+}
+
+// Read a character literal, which starts with #\
+func lvalReadChar(lit string) (*LVal, error) {
+	name := strings.TrimPrefix(lit, "#\\")
+
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) {
+		return &LVal{Type: LVAL_CHAR, Char: r}, nil
+	}
+
+	if r, ok := lvalCharNames[name]; ok {
+		return &LVal{Type: LVAL_CHAR, Char: r}, nil
+	}
+
+	if strings.HasPrefix(name, "x") {
+		r, err := strconv.ParseUint(name[1:], 16, 32)
+		if err == nil && utf8.ValidRune(rune(r)) {
+			return &LVal{Type: LVAL_CHAR, Char: rune(r)}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown character %s", lit)
+}
+
+// Characters print the way they are read, by name or code point if they can't be seen
+func lvalCharString(r rune) string {
+	for name, c := range lvalCharNames {
+		if c == r {
+			return "#\\" + name
+		}
+	}
+
+	if !unicode.IsGraphic(r) {
+		return "#\\x" + strconv.FormatInt(int64(r), 16)
+	}
+
+	return "#\\" + string(r)
+}
+
+// (char->int c) gives back the code point of a character
+func builtinCharToInt(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_CHAR {
+		return lvalErr("Function 'char->int' must be given a single character")
+	}
+
+	return lvalInt(int64(a.Cell[0].Char))
+}
+
+// (int->char n) gives back the character with code point n
+func builtinIntToChar(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_INT {
+		return lvalErr("Function 'int->char' must be given a single whole number")
+	}
+
+	n := a.Cell[0].Int
+	if n < 0 || n > unicode.MaxRune || !utf8.ValidRune(rune(n)) {
+		return lvalErr("Function 'int->char' was given " + strconv.FormatInt(n, 10) + ", which isn't a code point")
+	}
+
+	return lvalChar(rune(n))
+}
+
+// (string->chars s) gives back a q expression of the characters in s
+func builtinStringToChars(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_STR {
+		return lvalErr("Function 'string->chars' must be given a single string")
+	}
+
+	x := lvalQexpr()
+	for _, r := range a.Cell[0].String {
+		lvalAdd(x, lvalChar(r))
+	}
+
+	return x
+}
+
+// (chars->string cs) joins a q expression or vector of characters into a string
+func builtinCharsToString(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || (a.Cell[0].Type != LVAL_QEXPR && a.Cell[0].Type != LVAL_VECTOR) {
+		return lvalErr("Function 'chars->string' must be given a q expression or vector of characters")
+	}
+
+	chars := a.Cell[0].Cell
+	if a.Cell[0].Type == LVAL_VECTOR {
+		chars = a.Cell[0].Vec.items()
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(chars); i++ {
+		if chars[i].Type != LVAL_CHAR {
+			return lvalErr("Function 'chars->string' can only join characters")
+		}
+		b.WriteRune(chars[i].Char)
+	}
+
+	return lvalString(b.String())
+}
+
+// The character predicates, each checking a character with one of the functions from go's
+// unicode package
+var lvalCharPredicates = map[string]func(rune) bool{
+	"char-letter?":  unicode.IsLetter,
+	"char-digit?":   unicode.IsDigit,
+	"char-number?":  unicode.IsNumber,
+	"char-space?":   unicode.IsSpace,
+	"char-upper?":   unicode.IsUpper,
+	"char-lower?":   unicode.IsLower,
+	"char-punct?":   unicode.IsPunct,
+	"char-symbol?":  unicode.IsSymbol,
+	"char-control?": unicode.IsControl,
+	"char-graphic?": unicode.IsGraphic,
+}
+
+// Make the builtin for a character predicate. They all share this code, which is how the vm
+// knows they are pure.
+func builtinCharPredicate(name string, is func(rune) bool) LBuiltin {
+	return func(e *LEnv, a *LVal) *LVal {
+		if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_CHAR {
+			return lvalErr("Function '" + name + "' must be given a single character")
+		}
+
+		return lvalBool(is(a.Cell[0].Char))
+	}
+}
@@ -0,0 +1,28 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestChars(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list #\a #\newline #\space #\tab #\x3bb #\( #\λ)`, `{#\a #\newline #\space #\tab #\λ #\( #\λ}`},
+		{`(list (char->int #\A) (int->char 955) (string->chars "héllo") (chars->string [#\h #\i]) (chars->string {}))`, `{65 #\λ {#\h #\é #\l #\l #\o} "hi" ""}`},
+		{`(list (char-letter? #\é) (char-digit? #\7) (char-number? #\½) (char-upper? #\a) (char-lower? #\a))`, "{true true true false true}"},
+		{`(list (char-space? #\tab) (char-punct? #\!) (char-symbol? #\+) (char-control? #\x7) (char-graphic? #\a))`, "{true true true true true}"},
+		{`(list (== #\a #\a) (== #\a "a") (get #{#\a 1} #\a))`, "{true false 1}"},
+		{`(chars->string {#\a "b"})`, "error: Function 'chars->string' can only join characters"},
+		{`(int->char -1)`, "error: Function 'int->char' was given -1, which isn't a code point"},
+		{`(int->char 1114112)`, "error: Function 'int->char' was given 1114112, which isn't a code point"},
+		{`(char->int "a")`, "error: Function 'char->int' must be given a single character"},
+		{`(char-letter? 1)`, "error: Function 'char-letter?' must be given a single character"},
+	})
+}
+
+func TestCharLiteralErrors(t *testing.T) {
+	for _, src := range []string{`#\bogus`, `#\x110000`, `#\xzz`} {
+		if _, err := New().Parse(src); err == nil {
+			t.Errorf("%s parsed", src)
+		}
+	}
+}
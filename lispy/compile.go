@@ -0,0 +1,360 @@
+package lispy
+
+import (
+	"reflect"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the compiler, which lowers lvals into bytecode for the vm in vm.go.   //
+// The builtins that make up the language, like fn, if and the arithmetic, get compiled    //
+// into their own instructions while they still refer to the real builtins. Everything     //
+// else turns into a plain call, so the vm always does the same thing lvalEval would.      //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// The instructions of the vm. Operands follow the opcode in the code slice.
+const (
+	opConst      = iota // const index: push a constant
+	opLocal             // depth slot name: push a local from depth frames up
+	opGlobal            // name cache: push a value from the environment the code runs in
+	opClosure           // proto: push a new closure over the current frame
+	opMacroCheck        // form tail skip: if the function on the stack is a macro, compile form again and run it instead
+	opGuard             // sym value form tail skip cache: unless sym still refers to value, compile form again and run it instead
+	opCall              // argc form: call a function with the argc values above it
+	opTailCall          // argc form: same as opCall but in place of the current frame
+	opBranch            // else end: pop a condition and jump to else if it is false or nil
+	opJump              // addr: jump to addr
+	opReturn            // return the top of the stack from the current frame
+	opArith             // op argc form: run + - * or / on the top argc values
+	opCompare           // op form: run < > <= >= or == on the top two values
+)
+
+// The form operands are the s expression the instruction came from, for stack traces. A tail
+// operand is 1 when the form is in tail position. A cache operand indexes the caches of the proto.
+
+// A compiled function, or a top level expression which is a function with no formals
+type vmProto struct {
+	code   []int
+	consts []*LVal
+	protos []*vmProto
+	caches []vmCache
+
+	// The names of the slots in a frame. Formals come first, then the variable argument if there
+	// is one, then anything the body defines with =.
+	names    []string
+	nformals int
+	variadic bool
+
+	// What the function was compiled from, for printing and partial application
+	formals *LVal
+	body    *LVal
+}
+
+type vmCompiler struct {
+	proto *vmProto
+	par   *vmCompiler
+
+	// The environment the top level expression is being compiled for
+	env *LEnv
+}
+
+// Compile a top level expression that will be run in env. It is in tail position when it is run
+// in place of a call, like the code a builtin hands back.
+func vmCompile(env *LEnv, v *LVal, tail bool) *vmProto {
+	c := &vmCompiler{proto: &vmProto{}, env: env}
+	c.compileExpr(v, tail)
+	c.emit(opReturn)
+
+	return c.proto
+}
+
+func (c *vmCompiler) emit(op int, operands ...int) int {
+	pos := len(c.proto.code)
+	c.proto.code = append(c.proto.code, op)
+	c.proto.code = append(c.proto.code, operands...)
+
+	return pos
+}
+
+func (c *vmCompiler) constant(v *LVal) int {
+	c.proto.consts = append(c.proto.consts, v)
+	return len(c.proto.consts) - 1
+}
+
+// Make a new cache for a global lookup in the current function and get its index
+func (c *vmCompiler) cache() int {
+	c.proto.caches = append(c.proto.caches, vmCache{})
+	return len(c.proto.caches) - 1
+}
+
+// Find which frame and slot a symbol lives in, searching outwards from the current function
+func (c *vmCompiler) resolve(name string) (int, int, bool) {
+	depth := 0
+	for s := c; s != nil; s = s.par {
+		for i := len(s.proto.names) - 1; i >= 0; i-- {
+			if s.proto.names[i] == name {
+				return depth, i, true
+			}
+		}
+		depth++
+	}
+
+	return 0, 0, false
+}
+
+// Get what a symbol refers to right now if it isn't a local, so it can be checked for a builtin or macro
+func (c *vmCompiler) global(v *LVal) *LVal {
+	if v.Type != LVAL_SYM {
+		return nil
+	}
+
+	if _, _, ok := c.resolve(v.Sym); ok {
+		return nil
+	}
+
+	x := lenvGet(c.env, v)
+	if x.Type != LVAL_FUN {
+		return nil
+	}
+
+	return x
+}
+
+// Check whether f is the given builtin
+func vmIsBuiltin(f *LVal, b LBuiltin) bool {
+	return f.Builtin != nil && f.Code == nil && reflect.ValueOf(f.Builtin).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func (c *vmCompiler) compileExpr(v *LVal, tail bool) {
+	switch v.Type {
+	case LVAL_SYM:
+		if depth, slot, ok := c.resolve(v.Sym); ok {
+			c.emit(opLocal, depth, slot, c.constant(v))
+		} else {
+			c.emit(opGlobal, c.constant(v), c.cache())
+		}
+	case LVAL_SEXPR:
+		c.compileSexpr(v, tail)
+	default:
+		c.emit(opConst, c.constant(v))
+	}
+}
+
+func (c *vmCompiler) compileSexpr(v *LVal, tail bool) {
+	if len(v.Cell) == 0 {
+		c.emit(opConst, c.constant(v))
+		return
+	}
+
+	//What gets compiled for a builtin or macro is only right while the symbol still refers to it,
+	//so it is guarded by a check that compiles v again if it has been rebound since
+	if f := c.global(v.Cell[0]); f != nil {
+		ncode, nconsts, ncaches := len(c.proto.code), len(c.proto.consts), len(c.proto.caches)
+		guard := c.emit(opGuard, c.constant(v.Cell[0]), c.constant(f), c.constant(v), vmTail(tail), 0, c.cache())
+
+		if c.compileBuiltin(v, f, tail) {
+			c.proto.code[guard+5] = len(c.proto.code)
+			return
+		}
+
+		c.proto.code, c.proto.consts, c.proto.caches = c.proto.code[:ncode], c.proto.consts[:nconsts], c.proto.caches[:ncaches]
+	}
+
+	form := c.constant(v)
+
+	c.compileExpr(v.Cell[0], false)
+	check := c.emit(opMacroCheck, form, vmTail(tail), 0)
+
+	for i := 1; i < len(v.Cell); i++ {
+		c.compileExpr(v.Cell[i], false)
+	}
+
+	if tail {
+		c.emit(opTailCall, len(v.Cell)-1, form)
+	} else {
+		c.emit(opCall, len(v.Cell)-1, form)
+	}
+
+	c.proto.code[check+3] = len(c.proto.code)
+}
+
+// Compile a call to the builtin or macro f in its own way, reporting false if it is just a call
+func (c *vmCompiler) compileBuiltin(v *LVal, f *LVal, tail bool) bool {
+	switch {
+	case f.Macro:
+		//Macros get expanded once here rather than every time the code runs
+		a := lvalSexpr()
+		a.Cell = append(a.Cell, v.Cell[1:]...)
+		x := lvalMacroExpand(c.env, f, a)
+		if x.Type == LVAL_ERR {
+			x = lvalErrFrame(x, lvalCallName(v), v.Pos)
+		}
+
+		c.compileExpr(x, tail)
+		return true
+	case vmIsBuiltin(f, builtinLambda):
+		return c.compileLambda(v)
+	case vmIsBuiltin(f, builtinIf):
+		if len(v.Cell) == 4 && v.Cell[2].Type == LVAL_QEXPR && v.Cell[3].Type == LVAL_QEXPR {
+			c.compileIf(v, tail)
+			return true
+		}
+	}
+
+	if op, ok := vmArithOp(f); ok && len(v.Cell) > 1 {
+		for i := 1; i < len(v.Cell); i++ {
+			c.compileExpr(v.Cell[i], false)
+		}
+		c.emit(opArith, op, len(v.Cell)-1, c.constant(v))
+		return true
+	}
+
+	if op, ok := vmCompareOp(f); ok && len(v.Cell) == 3 {
+		c.compileExpr(v.Cell[1], false)
+		c.compileExpr(v.Cell[2], false)
+		c.emit(opCompare, op, c.constant(v))
+		return true
+	}
+
+	return false
+}
+
+func vmTail(tail bool) int {
+	if tail {
+		return 1
+	}
+
+	return 0
+}
+
+func (c *vmCompiler) compileIf(v *LVal, tail bool) {
+	c.compileExpr(v.Cell[1], false)
+	branch := c.emit(opBranch, 0, 0)
+
+	c.compileExpr(lvalAs(v.Cell[2], LVAL_SEXPR), tail)
+	jump := c.emit(opJump, 0)
+
+	c.proto.code[branch+1] = len(c.proto.code)
+	c.compileExpr(lvalAs(v.Cell[3], LVAL_SEXPR), tail)
+
+	c.proto.code[branch+2] = len(c.proto.code)
+	c.proto.code[jump+1] = len(c.proto.code)
+}
+
+// Compile (fn {formals} {body}) into a closure. Reports false if the lambda is malformed,
+// in which case it is left to builtinLambda to complain about at run time.
+func (c *vmCompiler) compileLambda(v *LVal) bool {
+	if len(v.Cell) != 3 || v.Cell[1].Type != LVAL_QEXPR || v.Cell[2].Type != LVAL_QEXPR {
+		return false
+	}
+
+	formals := v.Cell[1]
+	body := v.Cell[2]
+	p := &vmProto{formals: formals, body: body}
+
+	for i := 0; i < len(formals.Cell); i++ {
+		sym := formals.Cell[i]
+		if sym.Type != LVAL_SYM {
+			return false
+		}
+
+		if sym.Sym == "&" {
+			if i != len(formals.Cell)-2 || formals.Cell[i+1].Sym == "&" {
+				return false
+			}
+
+			p.variadic = true
+			p.names = append(p.names, formals.Cell[i+1].Sym)
+			break
+		}
+
+		p.names = append(p.names, sym.Sym)
+		p.nformals++
+	}
+
+	//Anything the body defines with = gets a slot of its own up front
+	vmScanLocals(body, p)
+
+	child := &vmCompiler{proto: p, par: c, env: c.env}
+	child.compileExpr(lvalAs(body, LVAL_SEXPR), true)
+	child.emit(opReturn)
+
+	c.proto.protos = append(c.proto.protos, p)
+	c.emit(opClosure, len(c.proto.protos)-1)
+
+	return true
+}
+
+// Look through a function body for (= {syms} ...) and give each symbol a slot. Nested
+// lambdas are skipped since they get frames of their own.
+func vmScanLocals(v *LVal, p *vmProto) {
+	if v.Type != LVAL_SEXPR && v.Type != LVAL_QEXPR {
+		return
+	}
+
+	if len(v.Cell) > 0 && v.Cell[0].Type == LVAL_SYM {
+		if v.Cell[0].Sym == "fn" {
+			return
+		}
+
+		if v.Cell[0].Sym == "=" && len(v.Cell) > 1 && v.Cell[1].Type == LVAL_QEXPR {
+			for i := 0; i < len(v.Cell[1].Cell); i++ {
+				sym := v.Cell[1].Cell[i]
+				if sym.Type == LVAL_SYM && !vmHasName(p, sym.Sym) {
+					p.names = append(p.names, sym.Sym)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < len(v.Cell); i++ {
+		vmScanLocals(v.Cell[i], p)
+	}
+}
+
+func vmHasName(p *vmProto, name string) bool {
+	for i := 0; i < len(p.names); i++ {
+		if p.names[i] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The arithmetic operators are numbered in the order of vmArithNames
+var vmArithNames = []string{"+", "-", "*", "/"}
+
+func vmArithOp(f *LVal) (int, bool) {
+	switch {
+	case vmIsBuiltin(f, builtinAdd):
+		return 0, true
+	case vmIsBuiltin(f, builtinSubtract):
+		return 1, true
+	case vmIsBuiltin(f, builtinMultiply):
+		return 2, true
+	case vmIsBuiltin(f, builtinDivide):
+		return 3, true
+	}
+
+	return 0, false
+}
+
+// The comparisons are numbered in the order of vmCompareNames
+var vmCompareNames = []string{"<", ">", "<=", ">=", "=="}
+
+func vmCompareOp(f *LVal) (int, bool) {
+	switch {
+	case vmIsBuiltin(f, builtinLessThan):
+		return 0, true
+	case vmIsBuiltin(f, builtinGreaterThan):
+		return 1, true
+	case vmIsBuiltin(f, builtinLessThanOrEqualTo):
+		return 2, true
+	case vmIsBuiltin(f, builtinGreaterThanOrEqualTo):
+		return 3, true
+	case vmIsBuiltin(f, builtinEq):
+		return 4, true
+	}
+
+	return 0, false
+}
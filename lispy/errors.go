@@ -0,0 +1,426 @@
+package lispy
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the builtins for raising and handling errors from lispy code. An     //
+// error stays an LVAL_ERR, cutting evaluation short, until try catches it. The handler     //
+// then gets it as an LVAL_COND, which is an ordinary value that can be looked at.          //
+//                                                                                          //
+// On top of that is a condition system like common lisp's. signal and throw first offer   //
+// the condition to the handlers from handler-bind, which run before anything is unwound   //
+// and can pick one of the restarts set up by restart-case to carry on from.               //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// A handler set up by handler-bind, or a try that is waiting to catch errors
+type lhandler struct {
+	// The type of condition handled, or "" for all of them
+	errType string
+	fn      *LVal
+
+	// Set instead of fn for a try
+	catches []*LVal
+}
+
+// A restart set up by restart-case. Invoking it unwinds back to the restart-case, which then
+// gives back the result of the restart's body.
+type lrestart struct {
+	name    string
+	formals *LVal
+	body    *LVal
+	env     *LEnv
+}
+
+// What invoke-restart panics with to unwind the go stack back to the restart-case
+type lrestartUnwind struct {
+	restart *lrestart
+	args    []*LVal
+}
+
+// (throw type message) or (throw type message value) raises a new error of the given type,
+// which are both strings. (throw e) raises an error that was caught by try again. Handlers get
+// to see the error first, and it is only raised if none of them invoke a restart.
+func builtinThrow(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 1 && a.Cell[0].Type == LVAL_COND {
+		x := *a.Cell[0]
+		x.Type = LVAL_ERR
+
+		return lvalSignal(e, &x, true)
+	}
+
+	x, err := builtinCondition(a, "throw")
+	if err != nil {
+		return err
+	}
+
+	return lvalSignal(e, x, true)
+}
+
+// (signal type message) or (signal type message value) offers a condition to the handlers
+// without raising it, giving back nil if none of them invoke a restart
+func builtinSignal(e *LEnv, a *LVal) *LVal {
+	x, err := builtinCondition(a, "signal")
+	if err != nil {
+		return err
+	}
+
+	//Anything other than x itself is an error from one of the handlers
+	if y := lvalSignal(e, x, false); y != x {
+		return y
+	}
+
+	return lvalNil()
+}
+
+// Build the error described by the arguments to throw or signal, or an error explaining what
+// is wrong with them if they don't describe one
+func builtinCondition(a *LVal, name string) (*LVal, *LVal) {
+	if len(a.Cell) != 2 && len(a.Cell) != 3 {
+		return nil, lvalErr("Function '" + name + "' must be given a type and a message, and optionally a value")
+	}
+
+	if a.Cell[0].Type != LVAL_STR || a.Cell[1].Type != LVAL_STR {
+		return nil, lvalErr("Function '" + name + "' must be given strings for the type and message")
+	}
+
+	x := lvalErr(a.Cell[1].String)
+	x.ErrType = a.Cell[0].String
+
+	if len(a.Cell) == 3 {
+		x.ErrValue = a.Cell[2]
+	}
+
+	return x, nil
+}
+
+// Offer the error x to the handlers in effect, innermost first. A handler that returns has
+// declined, and the next one gets a turn. Gives back x, or an error from a handler, unless one
+// of them invokes a restart, in which case this never returns. When raising, the debugger gets
+// a chance to pick a restart if nothing is going to catch x.
+func lvalSignal(e *LEnv, x *LVal, raising bool) *LVal {
+	root := lenvRoot(e)
+	handlers := root.handlers
+
+	cond := *x
+	cond.Type = LVAL_COND
+
+	for i := len(handlers) - 1; i >= 0; i-- {
+		h := handlers[i]
+
+		//A try that will catch this stops the search, since the handlers outside of it never see it
+		if h.fn == nil {
+			if raising && lvalCatchFor(h.catches, x) != nil {
+				return x
+			}
+			continue
+		}
+
+		if h.errType != "" && h.errType != x.ErrType {
+			continue
+		}
+
+		y := lvalRunHandler(root, handlers[:i:i], h, &cond)
+		if y.Type == LVAL_ERR {
+			return y
+		}
+	}
+
+	if raising && len(root.restarts) > 0 && root.debugger != nil {
+		restarts := make([]*lrestart, 0, len(root.restarts))
+		for i := len(root.restarts) - 1; i >= 0; i-- {
+			restarts = append(restarts, root.restarts[i])
+		}
+
+		if r, args := root.debugger(x, restarts); r != nil {
+			panic(&lrestartUnwind{restart: r, args: args})
+		}
+	}
+
+	return x
+}
+
+// Call a handler with only the handlers outside of it in effect, so that signalling from inside
+// a handler doesn't come back around to the same one
+func lvalRunHandler(root *LEnv, outer []*lhandler, h *lhandler, cond *LVal) *LVal {
+	saved := root.handlers
+	root.handlers = outer
+	defer func() { root.handlers = saved }()
+
+	args := lvalSexpr()
+	lvalAdd(args, cond)
+
+	return lvalForce(lvalCall(root, h.fn, args))
+}
+
+// (handler-bind handler {body}) or (handler-bind type handler {body}) evaluates body with
+// handler in effect for conditions of the given type, or for all of them. The handler is a
+// function that is given the condition while whatever signalled it is still waiting.
+func builtinHandlerBind(e *LEnv, a *LVal) *LVal {
+	h := &lhandler{}
+
+	switch {
+	case len(a.Cell) == 3 && a.Cell[0].Type == LVAL_STR:
+		h.errType = lvalPop(a, 0).String
+	case len(a.Cell) != 2:
+		return lvalErr("Function 'handler-bind' must be given a handler and a q expression, with an optional type first")
+	}
+
+	if a.Cell[0].Type != LVAL_FUN || a.Cell[1].Type != LVAL_QEXPR {
+		return lvalErr("Function 'handler-bind' must be given a handler function and a q expression to evaluate")
+	}
+
+	h.fn = a.Cell[0]
+
+	root := lenvRoot(e)
+	saved := root.handlers
+	root.handlers = append(saved[:len(saved):len(saved)], h)
+	defer func() { root.handlers = saved }()
+
+	return lvalEvalBody(e, a.Cell[1])
+}
+
+// (restart-case {body} {"name" {formals} {restart body}} ...) evaluates body with the given
+// restarts available. Invoking one of them with invoke-restart abandons body and gives back
+// what the restart's body evaluates to, with its formals bound to the arguments it was invoked
+// with.
+func builtinRestartCase(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'restart-case' must be given a q expression to evaluate")
+	}
+
+	mine := make([]*lrestart, 0, len(a.Cell)-1)
+	for i := 1; i < len(a.Cell); i++ {
+		c := a.Cell[i]
+		if c.Type != LVAL_QEXPR || len(c.Cell) != 3 || c.Cell[0].Type != LVAL_STR || c.Cell[1].Type != LVAL_QEXPR || c.Cell[2].Type != LVAL_QEXPR {
+			return lvalErr("Function 'restart-case' must be given restarts like {\"name\" {formals} {body}}")
+		}
+
+		mine = append(mine, &lrestart{name: c.Cell[0].String, formals: c.Cell[1], body: c.Cell[2], env: e})
+	}
+
+	x, unwind := lvalEvalRestartable(e, a.Cell[0], mine)
+	if unwind == nil {
+		return x
+	}
+
+	r := unwind.restart
+	args := lvalSexpr()
+	args.Cell = unwind.args
+
+	x = lvalCall(r.env, lvalLambda(r.env, r.formals, r.body), args)
+	if x.Type == LVAL_TAIL {
+		return lvalEvalBody(x.Env, r.body)
+	}
+
+	return x
+}
+
+// Evaluate body with the restarts in mine available. If one of them is invoked, gives back
+// what it was invoked with instead of a value.
+func lvalEvalRestartable(e *LEnv, body *LVal, mine []*lrestart) (x *LVal, unwind *lrestartUnwind) {
+	root := lenvRoot(e)
+	saved := root.restarts
+	root.restarts = append(saved[:len(saved):len(saved)], mine...)
+
+	defer func() {
+		root.restarts = saved
+
+		if p := recover(); p != nil {
+			u, ok := p.(*lrestartUnwind)
+			if !ok || !lrestartIn(u.restart, mine) {
+				panic(p)
+			}
+
+			unwind = u
+		}
+	}()
+
+	return lvalEvalBody(e, body), nil
+}
+
+func lrestartIn(r *lrestart, restarts []*lrestart) bool {
+	for i := 0; i < len(restarts); i++ {
+		if restarts[i] == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// (invoke-restart "name" args...) transfers control to the innermost restart with that name
+func builtinInvokeRestart(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_STR {
+		return lvalErr("Function 'invoke-restart' must be given the name of a restart")
+	}
+
+	restarts := lenvRoot(e).restarts
+	for i := len(restarts) - 1; i >= 0; i-- {
+		if restarts[i].name == a.Cell[0].String {
+			panic(&lrestartUnwind{restart: restarts[i], args: a.Cell[1:]})
+		}
+	}
+
+	return lvalErr("No restart named " + a.Cell[0].String + " is available")
+}
+
+// (try {body} {catch {e} {handler}} {finally {cleanup}}) evaluates body, and if it gives an
+// error, evaluates the handler of the first catch clause with the error bound to e. A catch
+// clause can be limited to one type of error, as in {catch "type" {e} {handler}}. The cleanup
+// of a finally clause is always evaluated last. Any number of catch clauses can be given, and
+// there can be at most one finally clause.
+func builtinTry(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'try' must be given a q expression to evaluate")
+	}
+
+	//Check every clause up front so a mistake in one doesn't wait until something is thrown to show
+	catches := []*LVal{}
+	var finally *LVal
+
+	for i := 1; i < len(a.Cell); i++ {
+		clause := a.Cell[i]
+
+		switch {
+		case lvalIsCatch(clause):
+			catches = append(catches, clause)
+		case lvalIsFinally(clause) && finally == nil:
+			finally = clause
+		default:
+			return lvalErr("Function 'try' must be given clauses like {catch {e} {handler}} or {finally {cleanup}}")
+		}
+	}
+
+	x, unwind := lvalEvalTry(e, a.Cell[0], catches)
+
+	if x != nil && x.Type == LVAL_ERR && !x.exit {
+		if c := lvalCatchFor(catches, x); c != nil {
+			cond := *x
+			cond.Type = LVAL_COND
+
+			frame := lenvNew(e)
+			lenvPut(frame, c.Cell[len(c.Cell)-2].Cell[0], &cond)
+
+			//A restart invoked from the handler still has to wait for the cleanup
+			x, unwind = lvalEvalUnwinding(frame, c.Cell[len(c.Cell)-1])
+		}
+	}
+
+	if finally != nil {
+		//An error in the cleanup takes the place of whatever the body gave
+		y := lvalEvalBody(e, finally.Cell[1])
+		if y.Type == LVAL_ERR && unwind == nil {
+			return y
+		}
+	}
+
+	//A restart being invoked carries on past the try once the cleanup is done
+	if unwind != nil {
+		panic(unwind)
+	}
+
+	return x
+}
+
+// Evaluate the body of a try with the try in effect as a handler, so that handlers outside of
+// it know not to handle what it will catch
+func lvalEvalTry(e *LEnv, body *LVal, catches []*LVal) (*LVal, *lrestartUnwind) {
+	root := lenvRoot(e)
+	saved := root.handlers
+	root.handlers = append(saved[:len(saved):len(saved)], &lhandler{catches: catches})
+	defer func() { root.handlers = saved }()
+
+	return lvalEvalUnwinding(e, body)
+}
+
+// Evaluate body, and if a restart is invoked, give back what it was invoked with instead of a
+// value so that the finally clause of a try can still run
+func lvalEvalUnwinding(e *LEnv, body *LVal) (x *LVal, unwind *lrestartUnwind) {
+	defer func() {
+		if p := recover(); p != nil {
+			u, ok := p.(*lrestartUnwind)
+			if !ok {
+				panic(p)
+			}
+
+			unwind = u
+		}
+	}()
+
+	return lvalEvalBody(e, body), nil
+}
+
+// Find the first of the catch clauses of a try that catches the error x
+func lvalCatchFor(catches []*LVal, x *LVal) *LVal {
+	for i := 0; i < len(catches); i++ {
+		c := catches[i].Cell
+
+		//Leave the error alone if the clause only catches some other type
+		if len(c) == 4 && c[1].String != x.ErrType {
+			continue
+		}
+
+		return catches[i]
+	}
+
+	return nil
+}
+
+// Check for {catch {e} {handler}} or {catch "type" {e} {handler}}
+func lvalIsCatch(v *LVal) bool {
+	if v.Type != LVAL_QEXPR || len(v.Cell) < 3 || len(v.Cell) > 4 {
+		return false
+	}
+
+	if v.Cell[0].Type != LVAL_SYM || v.Cell[0].Sym != "catch" {
+		return false
+	}
+
+	if len(v.Cell) == 4 && v.Cell[1].Type != LVAL_STR {
+		return false
+	}
+
+	name := v.Cell[len(v.Cell)-2]
+	body := v.Cell[len(v.Cell)-1]
+
+	return name.Type == LVAL_QEXPR && len(name.Cell) == 1 && name.Cell[0].Type == LVAL_SYM && body.Type == LVAL_QEXPR
+}
+
+// Check for {finally {cleanup}}
+func lvalIsFinally(v *LVal) bool {
+	return v.Type == LVAL_QEXPR && len(v.Cell) == 2 && v.Cell[0].Type == LVAL_SYM && v.Cell[0].Sym == "finally" && v.Cell[1].Type == LVAL_QEXPR
+}
+
+// Get the caught error out of the arguments to one of the functions below
+func builtinCaught(a *LVal, name string) (*LVal, *LVal) {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_COND {
+		return nil, lvalErr("Function '" + name + "' must be given an error caught by try")
+	}
+
+	return a.Cell[0], nil
+}
+
+func builtinErrorMessage(e *LEnv, a *LVal) *LVal {
+	x, err := builtinCaught(a, "error-message")
+	if err != nil {
+		return err
+	}
+
+	return lvalString(x.Err)
+}
+
+func builtinErrorType(e *LEnv, a *LVal) *LVal {
+	x, err := builtinCaught(a, "error-type")
+	if err != nil {
+		return err
+	}
+
+	return lvalString(x.ErrType)
+}
+
+func builtinErrorValue(e *LEnv, a *LVal) *LVal {
+	x, err := builtinCaught(a, "error-value")
+	if err != nil {
+		return err
+	}
+
+	return x.ErrValue
+}
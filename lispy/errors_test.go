@@ -0,0 +1,77 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestStackTraces(t *testing.T) {
+	tests := []lispyTest{
+		{"(def {f} (fn {x} {/ x 0}))\n(def {g} (fn {x} {+ 1 (f x)}))\n(g 1)", "error: Cannot divide by 0\n    at / (1:18)\n    at f (2:23)\n    at g (3:1)"},
+		//A tail call takes the place of the call it was made from, so only the last call to loop is left
+		{"(def {loop} (fn {n} {if (== n 0) {undefined} {loop (- n 1)}}))\n(loop 100)", "error: Unbound Symbol\n    at loop (1:46)"},
+		{"(head {})", "error: Function 'head' passed in an empty q expression\n    at head (1:1)"},
+		{"(eval {head {}})", "error: Function 'head' passed in an empty q expression\n    at head (1:7)"},
+		{"(try {throw \"a\" \"b\"} {catch {e} {throw e}})", "error: b\n    at throw (1:6)\n    at throw (1:33)\n    at try (1:1)"},
+	}
+
+	for _, test := range tests {
+		for _, treeWalk := range []bool{true, false} {
+			if got := vmCrossCheckEval(t, test.src, treeWalk); got != test.want {
+				t.Errorf("%s (tree walk %v): got %q, want %q", test.src, treeWalk, got, test.want)
+			}
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(try {g 1} {catch {e} {error-message e}})`, `"Unbound Symbol"`},
+		{`(try {throw "bad" "oops" {1 2}} {catch "bad" {e} {list (error-type e) (error-message e) (error-value e)}})`, `{"bad" "oops" {1 2}}`},
+		{`(try {throw "bad" "oops"} {catch "other" {e} {1}})`, "error: oops"},
+		{`(try {throw "bad" "oops"} {catch "other" {e} {1}} {catch "bad" {e} {2}} {catch {e} {3}})`, "2"},
+		{`(try {undefined} {catch {e} {list (error-type e) (error-value e)}})`, `{"error" nil}`},
+		{`(try {+ 1 2})`, "3"},
+		{`(def {x} 0) (list (try {1} {finally {def {x} 1}}) x)`, "{1 1}"},
+		{`(def {x} 0) (try {throw "a" "b"} {finally {def {x} 1}}) x`, "error: b"},
+		{`(def {x} 0) (try {try {throw "a" "b"} {finally {def {x} 1}}} {catch {e} {x}})`, "1"},
+		{`(try {+ 1 {}} {finally {throw "cleanup" "failed"}})`, "error: failed"},
+		{`(try {1} {catch})`, "error: Function 'try' must be given clauses like {catch {e} {handler}} or {finally {cleanup}}"},
+		{`(throw "a")`, "error: Function 'throw' must be given a type and a message, and optionally a value"},
+		{`(throw 1 "x")`, "error: Function 'throw' must be given strings for the type and message"},
+		{`(error-message 1)`, "error: Function 'error-message' must be given an error caught by try"},
+		{`(def {inc} (fn {x} {+ x 1})) (def {g} (fn {x} {try {inc x}})) (def {a} (g 1)) (def {inc} (fn {x} {- x 1})) (list a (g 1))`, "{2 0}"},
+		{`(defmacro {m} {x} {x}) (def {g} (fn {x} {try {m x}})) (def {a} (g 1)) (defmacro {m} {x} {99}) (list a (g 1))`, "{1 99}"},
+		{`(def {f} (fn {x} {try {throw "a" "b" x} {catch {e} {+ x (error-value e)}}})) (list (f 1) (f 2))`, "{2 4}"},
+	})
+}
+
+func TestRestarts(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(restart-case {invoke-restart "r" 5} {"r" {x} {* x 2}})`, "10"},
+		{`(list (restart-case {try {throw "a" "b"} {catch {e} {invoke-restart "r"}} {finally {def {x} 2}}} {"r" {} {1}}) x)`, "{1 2}"},
+		{`(restart-case {+ 1 2} {"r" {} {0}})`, "3"},
+		{`(invoke-restart "nope")`, "error: No restart named nope is available"},
+		{`(handler-bind "bad" (fn {c} {invoke-restart "skip"}) {restart-case {throw "bad" "x"} {"skip" {} {"skipped"}}})`, `"skipped"`},
+		{`(handler-bind "other" (fn {c} {invoke-restart "skip"}) {restart-case {throw "bad" "x"} {"skip" {} {"skipped"}}})`, "error: x"},
+		{`(restart-case {throw "bad" "x"} {"skip" {} {"skipped"}})`, "error: x"},
+		{`(handler-bind "bad" (fn {c} {list 1}) {try {throw "bad" "declined"} {catch {e} {error-message e}}})`, `"declined"`},
+		{`(handler-bind (fn {c} {invoke-restart "use" (error-value c)}) {restart-case {+ 1 (throw "bad" "x" 41)} {"use" {v} {+ v 1}}})`, "42"},
+		{`(handler-bind (fn {c} {invoke-restart "r" 1}) {restart-case {list (signal "note" "x") 2} {"r" {v} {v}}})`, "1"},
+	})
+}
+
+func TestSignal(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(signal "note" "hello")`, "nil"},
+		{`(handler-bind (fn {c} {error-value c}) {signal "note" "hello" 1})`, "nil"},
+		{`(handler-bind "note" (fn {c} {throw "bad" "from handler"}) {signal "note" "hello"})`, "error: from handler"},
+		{`(signal "note")`, "error: Function 'signal' must be given a type and a message, and optionally a value"},
+	})
+}
+
+func TestErrorValueDefaultsToNil(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(try {undefined} {catch {e} {error-value e}})`, "nil"},
+		{`(try {throw "bad" "no value"} {catch {e} {error-value e}})`, "nil"},
+	})
+}
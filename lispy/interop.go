@@ -0,0 +1,423 @@
+package lispy
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the golang interop. Any go function can be turned into a builtin by   //
+// looking at its signature with reflect, converting the lval arguments into go values,     //
+// calling it, and converting whatever comes back into lvals again.                         //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+var (
+	lvalGoType  = reflect.TypeOf((*LVal)(nil))
+	errorGoType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Add a go function to the environment passed in as a builtin with the given name
+func lenvAddGoFunc(e *LEnv, name string, fn interface{}) error {
+	f, err := lvalGoFunc(name, fn)
+	if err != nil {
+		return err
+	}
+
+	lenvAddBuiltin(e, name, f)
+	return nil
+}
+
+// Wrap a go function in a builtin that converts its arguments and results
+func lvalGoFunc(name string, fn interface{}) (LBuiltin, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot register %s: %T is not a function", name, fn)
+	}
+
+	ft := fv.Type()
+	for i := 0; i < ft.NumIn(); i++ {
+		if !goTypeSupported(ft.In(i)) {
+			return nil, fmt.Errorf("cannot register %s: argument %d has unsupported type %s", name, i+1, ft.In(i))
+		}
+	}
+
+	for i := 0; i < ft.NumOut(); i++ {
+		//A trailing error is turned into an error lval rather than converted
+		if i == ft.NumOut()-1 && ft.Out(i) == errorGoType {
+			continue
+		}
+
+		if !goTypeSupported(ft.Out(i)) {
+			return nil, fmt.Errorf("cannot register %s: result %d has unsupported type %s", name, i+1, ft.Out(i))
+		}
+	}
+
+	return func(e *LEnv, a *LVal) *LVal {
+		args, errVal := lvalGoArgs(name, ft, a)
+		if errVal != nil {
+			return errVal
+		}
+
+		return lvalGoCall(name, fv, args)
+	}, nil
+}
+
+// Call a go function, turning a panic inside of it into an error lval instead of letting it take
+// down the whole program. A restart invoked by lispy code that the function called back into
+// isn't a real panic though, and carries on unwinding.
+func lvalGoCall(name string, fv reflect.Value, args []reflect.Value) (x *LVal) {
+	defer func() {
+		if r := recover(); r != nil {
+			if u, ok := r.(*lrestartUnwind); ok {
+				panic(u)
+			}
+
+			x = lvalErr(fmt.Sprintf("Function '%s' panicked: %v", name, r))
+		}
+	}()
+
+	return lvalGoResults(fv.Call(args))
+}
+
+// Convert the cells of an lval into the arguments a go function of type ft expects
+func lvalGoArgs(name string, ft reflect.Type, a *LVal) ([]reflect.Value, *LVal) {
+	n := ft.NumIn()
+	cells := a.Cell
+
+	if ft.IsVariadic() {
+		if len(cells) < n-1 {
+			return nil, lvalErr(fmt.Sprintf("Function '%s' must be given at least %d arguments", name, n-1))
+		}
+	} else if len(cells) != n {
+		return nil, lvalErr(fmt.Sprintf("Function '%s' must be given %d arguments", name, n))
+	}
+
+	args := make([]reflect.Value, 0, len(cells))
+	for i := 0; i < len(cells); i++ {
+		var t reflect.Type
+		if ft.IsVariadic() && i >= n-1 {
+			t = ft.In(n - 1).Elem()
+		} else {
+			t = ft.In(i)
+		}
+
+		x, ok := lvalToGo(cells[i], t)
+		if !ok {
+			return nil, lvalErr(fmt.Sprintf("Function '%s' must be given %s as argument %d", name, goTypeDesc(t), i+1))
+		}
+
+		args = append(args, x)
+	}
+
+	return args, nil
+}
+
+// Convert the results of a go function call back into a single lval
+func lvalGoResults(results []reflect.Value) *LVal {
+	if len(results) > 0 && results[len(results)-1].Type() == errorGoType {
+		if err := results[len(results)-1]; !err.IsNil() {
+			return lvalErr(err.Interface().(error).Error())
+		}
+
+		results = results[:len(results)-1]
+	}
+
+	switch len(results) {
+	case 0:
+		return lvalSexpr()
+	case 1:
+		return lvalFromGo(results[0])
+	}
+
+	//Several results come back as a list
+	x := lvalQexpr()
+	for i := 0; i < len(results); i++ {
+		lvalAdd(x, lvalFromGo(results[i]))
+	}
+
+	return x
+}
+
+// Check whether values of a go type can be converted to and from lvals
+func goTypeSupported(t reflect.Type) bool {
+	if t == lvalGoType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice, reflect.Array:
+		return goTypeSupported(t.Elem())
+	case reflect.Map:
+		return goTypeSupported(t.Key()) && goTypeSupported(t.Elem())
+	case reflect.Ptr:
+		return goTypeSupported(t.Elem())
+	}
+
+	return false
+}
+
+// Describe what kind of lval a go type is converted from, for error messages
+func goTypeDesc(t reflect.Type) string {
+	if t == lvalGoType {
+		return "a value"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Float32, reflect.Float64:
+		return "a real number"
+	case reflect.Complex64, reflect.Complex128:
+		return "a number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a q expression"
+	case reflect.Map:
+		return "a map"
+	case reflect.Ptr:
+		return goTypeDesc(t.Elem())
+	}
+
+	return "a value"
+}
+
+// Convert an lval into a go value of type t. Reports false if the lval doesn't fit.
+func lvalToGo(v *LVal, t reflect.Type) (reflect.Value, bool) {
+	//Go code is free to change what it is given, so it gets a copy rather than a shared value
+	if t == lvalGoType {
+		return reflect.ValueOf(lvalCopy(v)), true
+	}
+
+	//Vectors convert the same way as q expressions
+	if v.Type == LVAL_VECTOR {
+		v = &LVal{Type: LVAL_QEXPR, Cell: v.Vec.items()}
+	}
+
+	x := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Type != LVAL_BOOL {
+			return x, false
+		}
+		x.SetBool(v.Bool)
+	case reflect.Float32, reflect.Float64:
+		if !lvalIsReal(v) {
+			return x, false
+		}
+		x.SetFloat(lvalToFloat(v))
+	case reflect.Complex64, reflect.Complex128:
+		if !lvalIsNumber(v) {
+			return x, false
+		}
+		x.SetComplex(lvalToComplex(v))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		//Characters can be handed to runes, which are int32s
+		if v.Type == LVAL_CHAR && t.Kind() == reflect.Int32 {
+			x.SetInt(int64(v.Char))
+			break
+		}
+
+		n, ok := lvalToInt64(v)
+		if !ok || x.OverflowInt(n) {
+			return x, false
+		}
+		x.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		//Big integers are only ever too big for an int64, but some of them still fit a uint64
+		if v.Type == LVAL_BIGINT && v.Big.IsUint64() && !x.OverflowUint(v.Big.Uint64()) {
+			x.SetUint(v.Big.Uint64())
+			break
+		}
+
+		n, ok := lvalToInt64(v)
+		if !ok || n < 0 || x.OverflowUint(uint64(n)) {
+			return x, false
+		}
+		x.SetUint(uint64(n))
+	case reflect.String:
+		if v.Type != LVAL_STR {
+			return x, false
+		}
+		x.SetString(v.String)
+	case reflect.Slice:
+		if v.Type != LVAL_QEXPR {
+			return x, false
+		}
+		x.Set(reflect.MakeSlice(t, len(v.Cell), len(v.Cell)))
+		for i := 0; i < len(v.Cell); i++ {
+			y, ok := lvalToGo(v.Cell[i], t.Elem())
+			if !ok {
+				return x, false
+			}
+			x.Index(i).Set(y)
+		}
+	case reflect.Array:
+		if v.Type != LVAL_QEXPR || len(v.Cell) != t.Len() {
+			return x, false
+		}
+		for i := 0; i < len(v.Cell); i++ {
+			y, ok := lvalToGo(v.Cell[i], t.Elem())
+			if !ok {
+				return x, false
+			}
+			x.Index(i).Set(y)
+		}
+	case reflect.Map:
+		if v.Type == LVAL_MAP {
+			x.Set(reflect.MakeMapWithSize(t, len(v.Map)))
+			for _, entry := range v.Map {
+				key, ok := lvalToGo(entry.key, t.Key())
+				if !ok {
+					return x, false
+				}
+				val, ok := lvalToGo(entry.val, t.Elem())
+				if !ok {
+					return x, false
+				}
+				x.SetMapIndex(key, val)
+			}
+			break
+		}
+
+		//Maps can also be written as a q expression of {key value} pairs
+		if v.Type != LVAL_QEXPR {
+			return x, false
+		}
+		x.Set(reflect.MakeMapWithSize(t, len(v.Cell)))
+		for i := 0; i < len(v.Cell); i++ {
+			pair := v.Cell[i]
+			if pair.Type != LVAL_QEXPR || len(pair.Cell) != 2 {
+				return x, false
+			}
+			key, ok := lvalToGo(pair.Cell[0], t.Key())
+			if !ok {
+				return x, false
+			}
+			val, ok := lvalToGo(pair.Cell[1], t.Elem())
+			if !ok {
+				return x, false
+			}
+			x.SetMapIndex(key, val)
+		}
+	case reflect.Ptr:
+		//nil is a nil pointer of any type
+		if v.Type == LVAL_NIL {
+			return x, true
+		}
+
+		y, ok := lvalToGo(v, t.Elem())
+		if !ok {
+			return x, false
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(y)
+		x.Set(p)
+	case reflect.Interface:
+		y := lvalToGoNatural(v)
+		if y.IsValid() {
+			x.Set(y)
+		}
+	default:
+		return x, false
+	}
+
+	return x, true
+}
+
+// Convert an lval into whatever go value it most naturally corresponds to
+func lvalToGoNatural(v *LVal) reflect.Value {
+	switch v.Type {
+	case LVAL_NIL:
+		return reflect.Value{}
+	case LVAL_BOOL:
+		return reflect.ValueOf(v.Bool)
+	case LVAL_INT:
+		return reflect.ValueOf(v.Int)
+	case LVAL_BIGINT:
+		return reflect.ValueOf(new(big.Int).Set(v.Big))
+	case LVAL_RAT:
+		return reflect.ValueOf(new(big.Rat).Set(v.Rat))
+	case LVAL_FLOAT:
+		return reflect.ValueOf(v.Float)
+	case LVAL_COMPLEX:
+		return reflect.ValueOf(v.Complex)
+	case LVAL_STR:
+		return reflect.ValueOf(v.String)
+	case LVAL_CHAR:
+		return reflect.ValueOf(v.Char)
+	case LVAL_SYM:
+		return reflect.ValueOf(v.Sym)
+	case LVAL_QEXPR:
+		x := make([]interface{}, len(v.Cell))
+		for i := 0; i < len(v.Cell); i++ {
+			if y := lvalToGoNatural(v.Cell[i]); y.IsValid() {
+				x[i] = y.Interface()
+			}
+		}
+		return reflect.ValueOf(x)
+	}
+
+	return reflect.ValueOf(v)
+}
+
+// Convert a go value back into an lval
+func lvalFromGo(x reflect.Value) *LVal {
+	if !x.IsValid() {
+		return lvalNil()
+	}
+
+	if x.Type() == lvalGoType {
+		if x.IsNil() {
+			return lvalNil()
+		}
+		return x.Interface().(*LVal)
+	}
+
+	switch x.Kind() {
+	case reflect.Bool:
+		return lvalBool(x.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lvalInt(x.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lvalBigInt(new(big.Int).SetUint64(x.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return lvalFloat(x.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return lvalComplex(x.Complex())
+	case reflect.String:
+		return lvalString(x.String())
+	case reflect.Slice, reflect.Array:
+		v := lvalQexpr()
+		for i := 0; i < x.Len(); i++ {
+			lvalAdd(v, lvalFromGo(x.Index(i)))
+		}
+		return v
+	case reflect.Map:
+		keys := x.MapKeys()
+		kvs := make([]*LVal, 0, 2*len(keys))
+		for i := 0; i < len(keys); i++ {
+			kvs = append(kvs, lvalFromGo(keys[i]), lvalFromGo(x.MapIndex(keys[i])))
+		}
+		return lvalMapPut("go", lvalMap(len(keys)), kvs)
+	case reflect.Ptr, reflect.Interface:
+		if x.IsNil() {
+			return lvalNil()
+		}
+		return lvalFromGo(x.Elem())
+	}
+
+	return lvalErr(fmt.Sprintf("Cannot convert go value of type %s", x.Type()))
+}
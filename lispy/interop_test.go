@@ -0,0 +1,130 @@
+package lispy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Go functions covering each kind of argument and result RegisterFunc converts
+var interopTestFuncs = map[string]interface{}{
+	"go/bool":    func(b bool) bool { return !b },
+	"go/int":     func(n int) int { return n * 2 },
+	"go/int8":    func(n int8) int8 { return n },
+	"go/uint64":  func(n uint64) uint64 { return n },
+	"go/float":   func(f float64) float64 { return f / 2 },
+	"go/complex": func(c complex128) complex128 { return c * c },
+	"go/string":  strings.ToUpper,
+	"go/rune":    func(r rune) rune { return r + 1 },
+	"go/slice":   func(xs []int) []int { return append(xs, len(xs)) },
+	"go/array":   func(xs [2]string) string { return xs[0] + xs[1] },
+	"go/map":     func(m map[string]int) int { return m["a"] + m["b"] },
+	"go/ptr":     func(p *int) bool { return p == nil },
+	"go/any":     func(x interface{}) interface{} { return x },
+	"go/lval":    func(v *LVal) *LVal { return lvalAdd(v, lvalInt(4)) },
+	"go/none":    func() string { return "called" },
+	"go/nothing": func(n int) {},
+	"go/two":     func(a, b int) (int, int) { return b, a },
+	"go/sum": func(start int, xs ...int) int {
+		for _, x := range xs {
+			start += x
+		}
+		return start
+	},
+	"go/check": func(n int) (int, error) {
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n, nil
+	},
+	"go/panic": func(s string) string { panic(s) },
+	"go/index": func(xs []int, i int) int { return xs[i] },
+}
+
+func TestGoFuncs(t *testing.T) {
+	tests := []lispyTest{
+		{`(go/bool true)`, "false"},
+		{`(go/int 21)`, "42"},
+		{`(go/int 1.5)`, "error: Function 'go/int' must be given a whole number as argument 1"},
+		{`(go/int8 300)`, "error: Function 'go/int8' must be given a whole number as argument 1"},
+		{`(go/uint64 18446744073709551615)`, "18446744073709551615"},
+		{`(go/uint64 -1)`, "error: Function 'go/uint64' must be given a whole number as argument 1"},
+		{`(list (go/float 3) (go/float (/ 1 2)))`, "{1.5 0.25}"},
+		{`(go/complex 1i)`, "-1+0i"},
+		{`(go/string "héllo")`, `"HÉLLO"`},
+		{`(go/string 1)`, "error: Function 'go/string' must be given a string as argument 1"},
+		{`(go/rune #\a)`, "98"},
+		{`(list (go/slice {1 2}) (go/slice [5]) (go/slice {}))`, "{{1 2 2} {5 1} {0}}"},
+		{`(go/slice {1 "a"})`, "error: Function 'go/slice' must be given a q expression as argument 1"},
+		{`(list (go/array {"a" "b"}) (go/array {"a"}))`, "error: Function 'go/array' must be given a q expression as argument 1"},
+		{`(list (go/map #{"a" 1 "b" 2}) (go/map {{"a" 3}}))`, "{3 3}"},
+		{`(list (go/ptr nil) (go/ptr 1))`, "{true false}"},
+		{`(list (go/any 1) (go/any "s") (go/any {1 {2 x}}) (go/any nil) (go/any #\x))`, `{1 "s" {1 {2 "x"}} nil 120}`},
+		{`(go/lval {1 2})`, "{1 2 4}"},
+		{`(list (apply go/none {}) (go/nothing 1) (go/two 1 2))`, `{"called" () {2 1}}`},
+		{`(go/none 1)`, "error: Function 'go/none' must be given 0 arguments"},
+		{`(list (go/none) (apply go/sum {1 2 3}))`, "{builtin 6}"},
+		{`(go/none {})`, "error: Function 'go/none' must be given 0 arguments"},
+		{`(go/int 1 2)`, "error: Function 'go/int' must be given 1 arguments"},
+		{`(list (go/sum 1) (go/sum 1 2 3))`, "{1 6}"},
+		{`(go/sum)`, "builtin"},
+		{`(go/sum {})`, "error: Function 'go/sum' must be given a whole number as argument 1"},
+		{`(list (go/check 1) (try {go/check -1} {catch {e} {error-message e}}))`, `{1 "negative"}`},
+		{`(go/panic "boom")`, "error: Function 'go/panic' panicked: boom"},
+		{`(go/index {1 2} 5)`, "error: Function 'go/index' panicked: runtime error: index out of range [5] with length 2"},
+		{`(try {go/panic "boom"} {catch {e} {error-type e}})`, `"error"`},
+	}
+
+	for _, test := range tests {
+		for _, treeWalk := range []bool{true, false} {
+			in := New()
+			in.TreeWalk = treeWalk
+
+			for name, fn := range interopTestFuncs {
+				if err := in.RegisterFunc(name, fn); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got string
+			v, err := in.EvalString(test.src)
+			if err != nil {
+				got = "error: " + err.Error()
+			} else {
+				got = Sprint(v)
+			}
+
+			if got != test.want {
+				t.Errorf("%s (tree walk %v): got %s, want %s", test.src, treeWalk, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRegisterFuncRejectsUnsupportedTypes(t *testing.T) {
+	in := New()
+
+	for _, fn := range []interface{}{1, func(chan int) {}, func() func() { return nil }, func(map[string]chan int) {}} {
+		if err := in.RegisterFunc("bad", fn); err == nil {
+			t.Errorf("registering %T succeeded", fn)
+		}
+	}
+}
+
+func TestRestartsUnwindThroughGoFuncs(t *testing.T) {
+	for _, treeWalk := range []bool{true, false} {
+		in := New()
+		in.TreeWalk = treeWalk
+
+		//A go function that calls back into lispy, which invokes a restart from outside of it
+		in.RegisterFunc("go/callback", func(src string) string {
+			in.EvalString(src)
+			return "not reached"
+		})
+
+		v, err := in.EvalString(`(restart-case {go/callback "(invoke-restart \"r\" 5)"} {"r" {x} {x}})`)
+		if err != nil || Sprint(v) != "5" {
+			t.Errorf("tree walk %v: got %v, %v", treeWalk, v, err)
+		}
+	}
+}
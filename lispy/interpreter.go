@@ -0,0 +1,332 @@
+package lispy
+
+import (
+	"fmt"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the public face of the interpreter. Go programs that want to embed    //
+// lispy create an Interpreter, hand it source text or files, and get values back. Anything //
+// more involved than that goes through the lval and lenv functions in the other files.     //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Value is a single lispy value, as returned from evaluation or passed into Define. Values are
+// shared rather than copied once lispy has them, so one given back by the interpreter mustn't
+// be changed.
+type Value = *LVal
+
+// Error is returned when lispy code evaluates to an error, as opposed to failing to parse.
+// Type is "error" unless the error was thrown with another type, along with Value. Trace holds
+// the calls the error came out of, innermost first.
+type Error struct {
+	Message string
+	Type    string
+	Value   Value
+	Trace   []LFrame
+
+	// Whether the error was raised by os/exit
+	exit bool
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// StackTrace formats the trace with one call per line, each with the file, line and column it
+// was made from
+func (err *Error) StackTrace() string {
+	var b strings.Builder
+	fprintTrace(&b, err.Trace)
+
+	return b.String()
+}
+
+// ExitCode gives back the code passed to os/exit if that is where the error came from
+func (err *Error) ExitCode() (int, bool) {
+	if !err.exit || err.Value == nil || err.Value.Type != LVAL_INT {
+		return 0, false
+	}
+
+	return int(err.Value.Int), true
+}
+
+// ParseError is returned when source text can't be parsed. Line and Column are where the parser
+// gave up, counting from 1, and File is set when the source came from a file.
+type ParseError struct {
+	Message string
+	File    string
+	Line    int
+	Column  int
+}
+
+func (err *ParseError) Error() string {
+	if err.File != "" {
+		return fmt.Sprintf("Parse error in %s on line %d, column %d: %s", err.File, err.Line, err.Column, err.Message)
+	}
+
+	return fmt.Sprintf("Parse error on line %d, column %d: %s", err.Line, err.Column, err.Message)
+}
+
+// Restart is one of the restarts set up by restart-case, as offered to a Debugger
+type Restart struct {
+	Name    string
+	Formals []string
+}
+
+// Interpreter holds a global environment that source text is evaluated in. Definitions made
+// by one call to EvalString or LoadFile are visible to every call after it.
+type Interpreter struct {
+	env *LEnv
+
+	// TreeWalk evaluates code by walking the lvals directly instead of compiling it for the vm.
+	// It is much slower but handy for checking the vm against.
+	TreeWalk bool
+
+	// Debugger is called when an error is thrown that nothing is going to catch while there are
+	// restarts available, innermost first. It gives back the index of the restart to invoke and
+	// the values to invoke it with, or -1 to let the error be raised.
+	Debugger func(err *Error, restarts []Restart) (int, []Value)
+}
+
+// New creates an interpreter whose global environment holds all of the builtin functions
+// along with the standard library modules
+func New() *Interpreter {
+	e := lenvNew(nil)
+	lenvAddBuiltins(e)
+	lenvAddStdlib(e)
+
+	in := &Interpreter{env: e}
+	e.debugger = in.debug
+
+	return in
+}
+
+// Hand the choice of restart over to the Debugger, if there is one
+func (in *Interpreter) debug(x *LVal, restarts []*lrestart) (*lrestart, []*LVal) {
+	if in.Debugger == nil {
+		return nil, nil
+	}
+
+	choices := make([]Restart, len(restarts))
+	for i := 0; i < len(restarts); i++ {
+		choices[i].Name = restarts[i].name
+
+		formals := restarts[i].formals.Cell
+		for j := 0; j < len(formals); j++ {
+			choices[i].Formals = append(choices[i].Formals, formals[j].Sym)
+		}
+	}
+
+	i, args := in.Debugger(&Error{Message: x.Err, Type: x.ErrType, Value: x.ErrValue, Trace: x.Trace}, choices)
+	if i < 0 || i >= len(restarts) {
+		return nil, nil
+	}
+
+	return restarts[i], args
+}
+
+// EvalString parses src and evaluates each top level expression in order, returning the value
+// of the last one. Errors raised while evaluating are returned as an *Error, and nothing is
+// evaluated if src doesn't parse, in which case the error is a *ParseError. Tagged literals are
+// read just before the expression they are in is evaluated, so one that can't be read is also a
+// *ParseError, but comes after the expressions before it have run.
+func (in *Interpreter) EvalString(src string) (Value, error) {
+	root, err := lispyParse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return in.evalAll(root)
+}
+
+// EvalEach is like EvalString, but hands the value or error of each top level expression to fn
+// as it goes, carrying on after errors for as long as fn gives back true. A *ParseError also
+// stops it, and is returned.
+func (in *Interpreter) EvalEach(src string, fn func(Value, error) bool) error {
+	root, err := lispyParse(src)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(root.Expressions); i++ {
+		v, err := lispyRead(in.env, root.Expressions[i])
+		if err != nil {
+			return err
+		}
+
+		if v != nil && !fn(in.eval(v)) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Parse reads src into its top level expressions without evaluating them. Since nothing in src
+// is run, tagged literals can only use reader macros that are already registered.
+func (in *Interpreter) Parse(src string) ([]Value, error) {
+	root, err := lispyParse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	forms := []Value{}
+	for i := 0; i < len(root.Expressions); i++ {
+		v, err := lispyRead(in.env, root.Expressions[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if v != nil {
+			forms = append(forms, v)
+		}
+	}
+
+	return forms, nil
+}
+
+// Eval evaluates a single expression, such as one of those returned by Parse
+func (in *Interpreter) Eval(v Value) (Value, error) {
+	return in.eval(v)
+}
+
+// Incomplete reports whether src has brackets or a string that haven't been closed yet, so a
+// REPL knows to keep reading instead of trying to parse it
+func Incomplete(src string) bool {
+	return lispyIncomplete(src)
+}
+
+// LoadFile evaluates every expression in the file at path, stopping at the first error
+func (in *Interpreter) LoadFile(path string) error {
+	root, err := lispyParseFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = in.evalAll(root)
+	return err
+}
+
+// EnableExit defines os/exit, which lispy code can't otherwise use to end the program. Calling
+// it gives back an *Error whose ExitCode is the code it was given, and leaves it to the embedder
+// to actually exit.
+func (in *Interpreter) EnableExit() {
+	lenvAddBuiltin(in.env, "os/exit", builtinOsExit)
+}
+
+// Define binds name to a copy of value in the global environment, so the caller is free to
+// change value afterwards
+func (in *Interpreter) Define(name string, value Value) {
+	lenvPut(in.env, lvalSym(name), lvalCopy(value))
+}
+
+// Symbols gives the sorted names of everything defined in the global environment, including the
+// builtins and standard library
+func (in *Interpreter) Symbols() []string {
+	return lenvNames(in.env)
+}
+
+// RegisterFunc makes a go function callable from lispy under the given name. Numbers, strings,
+// booleans, maps, q expressions (as slices, or as maps when they hold {key value} pairs) and
+// *LVal arguments are converted automatically, and a trailing error result is turned into a
+// lispy error.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	return lenvAddGoFunc(in.env, name, fn)
+}
+
+// Read and evaluate each of the top level expressions of root in turn, stopping at the first error
+func (in *Interpreter) evalAll(root *LISPY) (Value, error) {
+	x := lvalSexpr()
+
+	for i := 0; i < len(root.Expressions); i++ {
+		v, err := lispyRead(in.env, root.Expressions[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if v == nil {
+			continue
+		}
+
+		if x, err = in.eval(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return x, nil
+}
+
+// Evaluate a single expression, turning an error it evaluates to into an *Error
+func (in *Interpreter) eval(v *LVal) (Value, error) {
+	in.env.vm = !in.TreeWalk
+
+	var x *LVal
+	if in.TreeWalk {
+		x = lvalEval(in.env, v)
+	} else {
+		x = vmEval(in.env, v)
+	}
+
+	if x.Type == LVAL_ERR {
+		return nil, &Error{Message: x.Err, Type: x.ErrType, Value: x.ErrValue, Trace: x.Trace, exit: x.exit}
+	}
+
+	return x, nil
+}
+
+// Constructors for handing Go values over to Define. Each gives back a new value that belongs
+// to the caller, where the interpreter would share one for common values like small integers.
+
+// Number makes a lispy float
+func Number(x float64) Value {
+	return lvalFloat(x)
+}
+
+// Int makes a lispy integer
+func Int(x int64) Value {
+	return &LVal{Type: LVAL_INT, Int: x}
+}
+
+// Bool makes a lispy true or false
+func Bool(b bool) Value {
+	return &LVal{Type: LVAL_BOOL, Bool: b}
+}
+
+// Nil gives back lispy's nil
+func Nil() Value {
+	return &LVal{Type: LVAL_NIL}
+}
+
+// String makes a lispy string
+func String(str string) Value {
+	return lvalString(str)
+}
+
+// Symbol makes a lispy symbol
+func Symbol(sym string) Value {
+	return lvalSym(sym)
+}
+
+// List makes a q expression holding each of the given values
+func List(vals ...Value) Value {
+	x := lvalQexpr()
+	for i := 0; i < len(vals); i++ {
+		lvalAdd(x, vals[i])
+	}
+
+	return x
+}
+
+// Func makes a lispy function out of a builtin
+func Func(f LBuiltin) Value {
+	return lvalFun(f)
+}
+
+// Sprint formats a value the same way the REPL prints it
+func Sprint(v Value) string {
+	var b strings.Builder
+	fprintLVal(&b, v)
+
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package lispy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEvalString(t *testing.T) {
+	in := New()
+	in.Define("answer", Int(42))
+
+	v, err := in.EvalString("(* answer 2)")
+	if err != nil || Sprint(v) != "84" {
+		t.Errorf("got %v %v", v, err)
+	}
+
+	//Definitions carry over from one call to the next, and the last value is the one given back
+	if _, err := in.EvalString("(def {double} (fn {x} {* x 2}))"); err != nil {
+		t.Fatal(err)
+	}
+	v, err = in.EvalString("(double 1) (double answer)")
+	if err != nil || Sprint(v) != "84" {
+		t.Errorf("got %v %v", v, err)
+	}
+
+	v, err = in.EvalString("")
+	if err != nil || Sprint(v) != "()" {
+		t.Errorf("empty source: got %v %v", v, err)
+	}
+}
+
+func TestEvalStringErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.EvalString(`(throw "bad-record" "negative record" -1)`)
+	lerr, ok := err.(*Error)
+	if !ok || lerr.Type != "bad-record" || lerr.Message != "negative record" || Sprint(lerr.Value) != "-1" {
+		t.Errorf("got %#v", err)
+	}
+
+	_, err = in.EvalString("(head {})")
+	lerr, ok = err.(*Error)
+	if !ok || lerr.Type != "error" || len(lerr.Trace) != 1 || lerr.Trace[0].Name != "head" {
+		t.Errorf("got %#v", err)
+	}
+
+	//Evaluation stops at the first error
+	if _, err := in.EvalString("(def {x} 1) (head {}) (def {x} 2)"); err == nil {
+		t.Error("no error")
+	}
+	if v, err := in.EvalString("x"); err != nil || Sprint(v) != "1" {
+		t.Errorf("got %v %v", v, err)
+	}
+
+	_, err = in.EvalString("(+ 1")
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 1 || perr.Column != 5 {
+		t.Errorf("got %#v", err)
+	}
+}
+
+func TestParseAndEval(t *testing.T) {
+	in := New()
+
+	forms, err := in.Parse("(def {x} 3) #_(def {x} 4) (+ x 1)")
+	if err != nil || len(forms) != 2 {
+		t.Fatalf("got %v %v", forms, err)
+	}
+	if got := Sprint(forms[1]); got != "(+ x 1)" {
+		t.Errorf("parsed %s", got)
+	}
+
+	//Parsing on its own doesn't run anything
+	if _, err := in.EvalString("x"); err == nil {
+		t.Error("x was defined by Parse")
+	}
+
+	for i := 0; i < len(forms); i++ {
+		v, err := in.Eval(forms[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && Sprint(v) != "4" {
+			t.Errorf("got %v", v)
+		}
+	}
+}
+
+func TestEvalEach(t *testing.T) {
+	in := New()
+
+	got := []string{}
+	err := in.EvalEach("(def {x} 1) (head {}) (+ x 1)", func(v Value, err error) bool {
+		if err != nil {
+			got = append(got, "error: "+err.Error())
+		} else {
+			got = append(got, Sprint(v))
+		}
+		return true
+	})
+
+	want := []string{"()", "error: Function 'head' passed in an empty q expression", "2"}
+	if err != nil || strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got %q %v", got, err)
+	}
+
+	//Giving back false stops it
+	calls := 0
+	in.EvalEach("1 2 3", func(v Value, err error) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("called %d times", calls)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.lspy")
+	bad := filepath.Join(dir, "bad.lspy")
+
+	os.WriteFile(path, []byte("(def {square} (fn {x} {* x x}))\n(def {n} (square 3))\n"), 0644)
+	os.WriteFile(bad, []byte("(def {m} 1)\n(+ 1"), 0644)
+
+	in := New()
+	if err := in.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := in.EvalString("(square n)"); err != nil || Sprint(v) != "81" {
+		t.Errorf("got %v %v", v, err)
+	}
+
+	err := in.LoadFile(bad)
+	if perr, ok := err.(*ParseError); !ok || perr.File != bad || perr.Line != 2 {
+		t.Errorf("got %#v", err)
+	}
+	if _, err := in.EvalString("m"); err == nil {
+		t.Error("m was defined by a file that didn't parse")
+	}
+
+	if err := in.LoadFile(filepath.Join(dir, "missing.lspy")); !os.IsNotExist(err) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	in := New()
+	in.Define("zzz", Nil())
+
+	names := in.Symbols()
+	if !sort.StringsAreSorted(names) {
+		t.Error("symbols aren't sorted")
+	}
+
+	for _, name := range []string{"+", "def", "math/sqrt", "zzz"} {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			t.Errorf("%s is missing", name)
+		}
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	in := New()
+	in.Define("f", Number(1.5))
+	in.Define("n", Int(2))
+	in.Define("b", Bool(false))
+	in.Define("none", Nil())
+	in.Define("s", String("hi"))
+	in.Define("sym", Symbol("x"))
+	in.Define("xs", List(Int(1), String("a"), List()))
+	in.Define("count-args", Func(func(e *LEnv, a *LVal) *LVal {
+		return lvalInt(int64(len(a.Cell)))
+	}))
+
+	v, err := in.EvalString("(list f n b none s sym xs (count-args 1 2 3))")
+	if err != nil || Sprint(v) != `{1.5 2 false nil "hi" x {1 "a" {}} 3}` {
+		t.Errorf("got %v %v", v, err)
+	}
+
+	//Define takes a copy, so changing the value afterwards doesn't change what lispy sees
+	xs := List(Int(1), Int(2))
+	in.Define("ys", xs)
+	xs.Cell[0].Int = 10
+	xs.Cell = xs.Cell[:1]
+
+	v, err = in.EvalString("ys")
+	if err != nil || Sprint(v) != "{1 2}" {
+		t.Errorf("got %v %v", v, err)
+	}
+}
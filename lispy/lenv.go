@@ -0,0 +1,177 @@
+package lispy
+
+import (
+	"sort"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains all of the functionality for the environment handling of different    //
+// functions and also handles the initial adding of builtin functions to the global env.    //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Each environment maps symbol names straight to their values, so looking a symbol up costs the
+// same no matter how much is defined. Par links a function call's frame to where it was defined.
+type LEnv struct {
+	Par  *LEnv
+	Vals map[string]*LVal
+
+	// Goes up every time something is put into Vals, so the vm can tell when a global it looked up
+	// before might have changed. See vmCache.
+	version int
+
+	// The handlers and restarts that are in effect right now, innermost last. Only the global
+	// environment uses these, since they follow the calls being made rather than where code was
+	// written. See errors.go.
+	handlers []*lhandler
+	restarts []*lrestart
+
+	// The reader macros for tagged literals, by tag. Only the global environment has these.
+	readers map[string]*LVal
+
+	// Whether code is being run by the vm rather than lvalEval, and the bodies of builtins like try
+	// that have been compiled for it. Only the global environment uses these.
+	vm     bool
+	bodies map[*LVal]*vmProto
+
+	// Offered the chance to pick a restart when an error is thrown that nothing will catch
+	debugger func(err *LVal, restarts []*lrestart) (*lrestart, []*LVal)
+}
+
+// Make a new empty environment whose parent is par. The global environment has no parent.
+func lenvNew(par *LEnv) *LEnv {
+	return &LEnv{Par: par, Vals: make(map[string]*LVal)}
+}
+
+// Get a value out of an environment or its parent chain
+func lenvGet(env *LEnv, x *LVal) *LVal {
+	// Check if the requested symbol is in the environment and get it. If not, it may be in the parent environment
+	for ; env != nil; env = env.Par {
+		v, ok := env.Vals[x.Sym]
+		if !ok {
+			continue
+		}
+
+		//Values are never changed after they are made so they can be handed out as they are
+		return v
+	}
+
+	return lvalErr("Unbound Symbol")
+}
+
+// Get the name of every symbol bound in an environment or its parent chain, sorted and without
+// duplicates
+func lenvNames(env *LEnv) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for ; env != nil; env = env.Par {
+		for name := range env.Vals {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Add a value into the environment, overwriting whatever was there before
+func lenvPut(env *LEnv, key *LVal, val *LVal) {
+	env.Vals[key.Sym] = val
+	env.version++
+}
+
+// Define a new variable or function
+func lenvDef(env *LEnv, key *LVal, val *LVal) {
+	lenvPut(lenvRoot(env), key, val)
+}
+
+// Get the global environment at the end of env's parent chain
+func lenvRoot(env *LEnv) *LEnv {
+	for env.Par != nil {
+		env = env.Par
+	}
+
+	return env
+}
+
+// Add a builtin function to the environment passed in
+func lenvAddBuiltin(e *LEnv, name string, f LBuiltin) {
+	k := lvalSym(name)
+	v := lvalFun(f)
+	lenvPut(e, k, v)
+}
+
+// Initialize the global environment with the suite of builtin functions
+func lenvAddBuiltins(e *LEnv) {
+	lenvAddBuiltin(e, "list", builtinList)
+	lenvAddBuiltin(e, "head", builtinHead)
+	lenvAddBuiltin(e, "tail", builtinTail)
+	lenvAddBuiltin(e, "eval", builtinEval)
+	lenvAddBuiltin(e, "apply", builtinApply)
+	lenvAddBuiltin(e, "join", builtinJoin)
+	lenvAddBuiltin(e, "def", builtinDef)
+	lenvAddBuiltin(e, "=", builtinPut)
+	lenvAddBuiltin(e, "fn", builtinLambda)
+	lenvAddBuiltin(e, "defmacro", builtinDefMacro)
+	lenvAddBuiltin(e, "macroexpand", builtinMacroExpand)
+	lenvAddBuiltin(e, "quote", builtinQuote)
+	lenvAddBuiltin(e, "quasiquote", builtinQuasiquote)
+	lenvAddBuiltin(e, "unquote", builtinUnquote)
+	lenvAddBuiltin(e, "unquote-splicing", builtinUnquoteSplicing)
+	lenvAddBuiltin(e, "+", builtinAdd)
+	lenvAddBuiltin(e, "-", builtinSubtract)
+	lenvAddBuiltin(e, "*", builtinMultiply)
+	lenvAddBuiltin(e, "/", builtinDivide)
+	lenvAddBuiltin(e, "<", builtinLessThan)
+	lenvAddBuiltin(e, ">", builtinGreaterThan)
+	lenvAddBuiltin(e, "<=", builtinLessThanOrEqualTo)
+	lenvAddBuiltin(e, ">=", builtinGreaterThanOrEqualTo)
+	lenvAddBuiltin(e, "==", builtinEq)
+	lenvAddBuiltin(e, "if", builtinIf)
+	lenvAddBuiltin(e, "load", builtinLoad)
+	lenvAddBuiltin(e, "print", builtinPrint)
+	lenvAddBuiltin(e, "throw", builtinThrow)
+	lenvAddBuiltin(e, "try", builtinTry)
+	lenvAddBuiltin(e, "error-message", builtinErrorMessage)
+	lenvAddBuiltin(e, "error-type", builtinErrorType)
+	lenvAddBuiltin(e, "error-value", builtinErrorValue)
+	lenvAddBuiltin(e, "signal", builtinSignal)
+	lenvAddBuiltin(e, "handler-bind", builtinHandlerBind)
+	lenvAddBuiltin(e, "restart-case", builtinRestartCase)
+	lenvAddBuiltin(e, "invoke-restart", builtinInvokeRestart)
+	lenvAddBuiltin(e, "hash-map", builtinHashMap)
+	lenvAddBuiltin(e, "get", builtinGet)
+	lenvAddBuiltin(e, "assoc", builtinAssoc)
+	lenvAddBuiltin(e, "dissoc", builtinDissoc)
+	lenvAddBuiltin(e, "keys", builtinKeys)
+	lenvAddBuiltin(e, "vals", builtinVals)
+	lenvAddBuiltin(e, "contains?", builtinContains)
+	lenvAddBuiltin(e, "merge", builtinMerge)
+	lenvAddBuiltin(e, "vector", builtinVector)
+	lenvAddBuiltin(e, "nth", builtinNth)
+	lenvAddBuiltin(e, "count", builtinCount)
+	lenvAddBuiltin(e, "slice", builtinSlice)
+	lenvAddBuiltin(e, "push", builtinPush)
+	lenvAddBuiltin(e, "list->vector", builtinListToVector)
+	lenvAddBuiltin(e, "vector->list", builtinVectorToList)
+	lenvAddBuiltin(e, "str-len", builtinStrLen)
+	lenvAddBuiltin(e, "substr", builtinSubstr)
+	lenvAddBuiltin(e, "str-index", builtinStrIndex)
+	lenvAddBuiltin(e, "upper", builtinUpper)
+	lenvAddBuiltin(e, "lower", builtinLower)
+	lenvAddBuiltin(e, "split", builtinSplit)
+	lenvAddBuiltin(e, "join-str", builtinJoinStr)
+	lenvAddBuiltin(e, "trim", builtinTrim)
+	lenvAddBuiltin(e, "replace", builtinReplace)
+	lenvAddBuiltin(e, "char->int", builtinCharToInt)
+	lenvAddBuiltin(e, "int->char", builtinIntToChar)
+	lenvAddBuiltin(e, "string->chars", builtinStringToChars)
+	lenvAddBuiltin(e, "chars->string", builtinCharsToString)
+	lenvAddBuiltin(e, "reader-macro", builtinReaderMacro)
+	for name, is := range lvalCharPredicates {
+		lenvAddBuiltin(e, name, builtinCharPredicate(name, is))
+	}
+}
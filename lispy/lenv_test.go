@@ -0,0 +1,31 @@
+package lispy
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Lookups should cost the same however many symbols the global environment holds
+
+func benchmarkLenvGet(b *testing.B, size int) {
+	e := lenvNew(nil)
+	lenvAddBuiltins(e)
+
+	for i := 0; i < size; i++ {
+		lenvPut(e, lvalSym(fmt.Sprintf("sym%d", i)), lvalInt(int64(i)))
+	}
+
+	//Look up from inside a couple of call frames, the way a function body would
+	frame := lenvNew(lenvNew(e))
+	sym := lvalSym(fmt.Sprintf("sym%d", size/2))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lenvGet(frame, sym)
+	}
+}
+
+func BenchmarkLenvGet10(b *testing.B)    { benchmarkLenvGet(b, 10) }
+func BenchmarkLenvGet100(b *testing.B)   { benchmarkLenvGet(b, 100) }
+func BenchmarkLenvGet1000(b *testing.B)  { benchmarkLenvGet(b, 1000) }
+func BenchmarkLenvGet10000(b *testing.B) { benchmarkLenvGet(b, 10000) }
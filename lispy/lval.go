@@ -0,0 +1,880 @@
+package lispy
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the bread and butter of the actual interpreter. It contains the lval  //
+// type along with functions to manipulate, build, delete, and process interpreter nodes of //
+// all types. Also, it contains the functionality for translating between participle struct //
+// nodes and lval nodes.																	//
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+type LValType int
+
+const (
+	LVAL_ERR LValType = iota
+
+	// The numeric tower, in order. Integers that overflow int64 become big integers, and
+	// arithmetic mixing two kinds of number gives the later kind.
+	LVAL_INT
+	LVAL_BIGINT
+	LVAL_RAT
+	LVAL_FLOAT
+	LVAL_COMPLEX
+
+	LVAL_SYM
+	LVAL_SEXPR
+	LVAL_QEXPR
+	LVAL_FUN
+	LVAL_STR
+	LVAL_CHAR
+	LVAL_BOOL
+	LVAL_NIL
+	LVAL_MAP
+	LVAL_VECTOR
+
+	// An error that was caught by try. Unlike LVAL_ERR it doesn't cut evaluation short, so it
+	// can be bound to a symbol and passed around like any other value.
+	LVAL_COND
+
+	// Never seen by lispy code. Returned by builtins to ask lvalEval to carry on evaluating
+	// Body in Env without growing the go stack, which is how tail calls are made cheap. When
+	// Body is the body of a lambda, Sym and Pos name the call for stack traces.
+	LVAL_TAIL
+)
+
+// One call in the stack trace of an error
+type LFrame struct {
+	Name string
+	Pos  lexer.Position
+}
+
+type LVal struct {
+	// Type
+	Type LValType
+
+	// Basic
+	Int     int64
+	Big     *big.Int
+	Rat     *big.Rat
+	Float   float64
+	Complex complex128
+	Err     string
+	Sym     string
+	String  string
+	Char    rune
+	Bool    bool
+
+	// Errors have a type, which is "error" unless they were thrown with another one, and
+	// whatever value was thrown along with them
+	ErrType  string
+	ErrValue *LVal
+
+	// Set on the error raised by os/exit, which try won't catch. Being unexported, lispy code has
+	// no way of making an error look like one by throwing it.
+	exit bool
+
+	// Function. Macro sits next to exit so the two bools share a word.
+	Macro   bool
+	Builtin LBuiltin
+	Env     *LEnv
+	Formals *LVal
+	Body    *LVal
+
+	// Compiled function, set when a lambda was compiled by the vm. Builtin is set as well so
+	// that it can still be called like any other builtin.
+	Code *vmClosure
+
+	// Cells
+	Cell []*LVal
+
+	// Map entries, by the hash string of their key
+	Map map[string]lmapEntry
+
+	// Vector elements
+	Vec *lvector
+
+	// Where the lval was read from, if it came from source text
+	Pos lexer.Position
+
+	// The calls an error passed through on its way out, innermost first
+	Trace []LFrame
+}
+
+//Constructors for different kinds of lvals
+
+func lvalString(str string) *LVal {
+	val := LVal{Type: LVAL_STR, String: str}
+	return &val
+}
+
+func lvalFun(builtin LBuiltin) *LVal {
+	val := LVal{Type: LVAL_FUN, Builtin: builtin}
+	return &val
+}
+
+// Small integers come up constantly in loops and counters. Values are never changed once made,
+// so they are made once up front and shared.
+var lvalSmallInts [1024]*LVal
+
+func init() {
+	for i := 0; i < len(lvalSmallInts); i++ {
+		lvalSmallInts[i] = &LVal{Type: LVAL_INT, Int: int64(i)}
+	}
+}
+
+func lvalInt(x int64) *LVal {
+	if x >= 0 && x < int64(len(lvalSmallInts)) {
+		return lvalSmallInts[x]
+	}
+
+	val := LVal{Type: LVAL_INT, Int: x}
+	return &val
+}
+
+// Big integers that fit in an int64 are always made into plain integers
+func lvalBigInt(x *big.Int) *LVal {
+	if x.IsInt64() {
+		return lvalInt(x.Int64())
+	}
+
+	val := LVal{Type: LVAL_BIGINT, Big: x}
+	return &val
+}
+
+// Rationals that come out whole are always made into integers
+func lvalRat(x *big.Rat) *LVal {
+	if x.IsInt() {
+		return lvalBigInt(new(big.Int).Set(x.Num()))
+	}
+
+	val := LVal{Type: LVAL_RAT, Rat: x}
+	return &val
+}
+
+func lvalFloat(x float64) *LVal {
+	val := LVal{Type: LVAL_FLOAT, Float: x}
+	return &val
+}
+
+func lvalComplex(x complex128) *LVal {
+	val := LVal{Type: LVAL_COMPLEX, Complex: x}
+	return &val
+}
+
+// There are only ever two booleans and one nil, which everything shares
+var (
+	lvalTrueVal  = &LVal{Type: LVAL_BOOL, Bool: true}
+	lvalFalseVal = &LVal{Type: LVAL_BOOL, Bool: false}
+	lvalNilVal   = &LVal{Type: LVAL_NIL}
+)
+
+func lvalBool(b bool) *LVal {
+	if b {
+		return lvalTrueVal
+	}
+
+	return lvalFalseVal
+}
+
+func lvalNil() *LVal {
+	return lvalNilVal
+}
+
+func lvalErr(x string) *LVal {
+	val := LVal{Type: LVAL_ERR, Err: x, ErrType: "error", ErrValue: lvalNil()}
+	return &val
+}
+
+func lvalSym(x string) *LVal {
+	val := LVal{Type: LVAL_SYM, Sym: x}
+	return &val
+}
+
+func lvalSexpr() *LVal {
+	val := LVal{Type: LVAL_SEXPR}
+	return &val
+}
+
+func lvalQexpr() *LVal {
+	val := LVal{Type: LVAL_QEXPR}
+	return &val
+}
+
+// Ask lvalEval to evaluate v in e in place of the current call
+func lvalTail(e *LEnv, v *LVal) *LVal {
+	val := LVal{Type: LVAL_TAIL, Env: e, Body: v}
+	return &val
+}
+
+// A lambda keeps a reference to the environment it was made in, which is what makes it a closure
+func lvalLambda(env *LEnv, formals *LVal, body *LVal) *LVal {
+	v := LVal{Type: LVAL_FUN}
+
+	v.Builtin = nil
+	v.Env = env
+	v.Formals = formals
+	v.Body = body
+
+	return &v
+}
+
+// Functions that aid in printing lvals
+
+func fprintLValExpr(w io.Writer, l *LVal, openChar string, closeChar string) {
+	fmt.Fprint(w, openChar)
+
+	for i := 0; i < len(l.Cell); i++ {
+		fprintLVal(w, l.Cell[i])
+		if i != len(l.Cell)-1 {
+			fmt.Fprint(w, " ")
+		}
+	}
+
+	fmt.Fprint(w, closeChar)
+}
+
+func fprintLVal(w io.Writer, l *LVal) {
+	switch l.Type {
+	case LVAL_INT, LVAL_BIGINT, LVAL_RAT, LVAL_FLOAT, LVAL_COMPLEX:
+		fmt.Fprint(w, lvalNumString(l))
+	case LVAL_ERR:
+		fmt.Fprint(w, l.Err)
+	case LVAL_COND:
+		fmt.Fprint(w, "<"+l.ErrType+": "+l.Err+">")
+	case LVAL_SYM:
+		fmt.Fprint(w, l.Sym)
+	case LVAL_STR:
+		fmt.Fprint(w, "\""+lvalEscapeStr(l.String)+"\"")
+	case LVAL_CHAR:
+		fmt.Fprint(w, lvalCharString(l.Char))
+	case LVAL_BOOL:
+		if l.Bool {
+			fmt.Fprint(w, "true")
+		} else {
+			fmt.Fprint(w, "false")
+		}
+	case LVAL_NIL:
+		fmt.Fprint(w, "nil")
+	case LVAL_MAP:
+		fmt.Fprint(w, "#{")
+		for i, k := range lvalMapKeys(l) {
+			if i > 0 {
+				fmt.Fprint(w, " ")
+			}
+			fprintLVal(w, l.Map[k].key)
+			fmt.Fprint(w, " ")
+			fprintLVal(w, l.Map[k].val)
+		}
+		fmt.Fprint(w, "}")
+	case LVAL_VECTOR:
+		fmt.Fprint(w, "[")
+		for i := 0; i < l.Vec.count; i++ {
+			if i > 0 {
+				fmt.Fprint(w, " ")
+			}
+			fprintLVal(w, l.Vec.nth(i))
+		}
+		fmt.Fprint(w, "]")
+	case LVAL_SEXPR:
+		fprintLValExpr(w, l, "(", ")")
+	case LVAL_QEXPR:
+		fprintLValExpr(w, l, "{", "}")
+	case LVAL_FUN:
+		if l.Builtin != nil && l.Code == nil {
+			fmt.Fprint(w, "builtin")
+		} else {
+			if l.Macro {
+				fmt.Fprint(w, "(macro ")
+			} else {
+				fmt.Fprint(w, "(\\ ")
+			}
+			fprintLVal(w, l.Formals)
+			fmt.Fprint(w, " ")
+			fprintLVal(w, l.Body)
+			fmt.Fprint(w, ")")
+		}
+	}
+}
+
+func printLVal(l *LVal) {
+	fprintLVal(os.Stdout, l)
+}
+
+// Print the stack trace of an error, one call per line
+func fprintTrace(w io.Writer, trace []LFrame) {
+	for i := 0; i < len(trace); i++ {
+		fmt.Fprint(w, "\n    at "+trace[i].Name)
+
+		//Code built by macros or eval has no position to point at
+		if trace[i].Pos.Line != 0 {
+			fmt.Fprint(w, " ("+trace[i].Pos.String()+")")
+		}
+	}
+}
+
+// Helper functions for processing lvals
+
+//Add an input lval to the first lval's cell
+func lvalAdd(v *LVal, x *LVal) *LVal {
+	v.Cell = append(v.Cell, x)
+	return v
+}
+
+// Once an lval has been made and handed out it is never changed, so values get shared freely
+// between environments and lists instead of being copied. Only lvals that a function has just
+// built itself, like a fresh argument list, get modified with lvalAdd or lvalPop.
+
+// Make a new list of type t that shares the cells of v, like turning a q expression into code
+func lvalAs(v *LVal, t LValType) *LVal {
+	x := LVal{Type: t, Pos: v.Pos}
+
+	//Capping the capacity means appending to the new list can never write into v's cells
+	x.Cell = v.Cell[:len(v.Cell):len(v.Cell)]
+	return &x
+}
+
+//Pop an element at a certain index from the given lval cell
+func lvalPop(v *LVal, i int) *LVal {
+	x := v.Cell[i]
+
+	//The cells may be shared with another list, so build a new slice rather than shifting them in place
+	if i == 0 {
+		v.Cell = v.Cell[1:]
+	} else {
+		v.Cell = append(v.Cell[:i:i], v.Cell[i+1:]...)
+	}
+	return x
+}
+
+//Same as pop really, this was just part of the book so I kept it consistent
+func lvalTake(v *LVal, i int) *LVal {
+	x := lvalPop(v, i)
+	return x
+}
+
+//Return a deep copy of an lval. Only needed when handing values to code outside of the interpreter.
+func lvalCopy(v *LVal) *LVal {
+	x := LVal{Cell: make([]*LVal, 0)}
+	x.Type = v.Type
+	x.Pos = v.Pos
+
+	switch v.Type {
+	case LVAL_FUN:
+		//Functions never change once they are made, so the copy can share everything
+		x.Builtin = v.Builtin
+		x.Env = v.Env
+		x.Formals = v.Formals
+		x.Body = v.Body
+		x.Macro = v.Macro
+		x.Code = v.Code
+	case LVAL_INT:
+		x.Int = v.Int
+	case LVAL_BIGINT:
+		x.Big = new(big.Int).Set(v.Big)
+	case LVAL_RAT:
+		x.Rat = new(big.Rat).Set(v.Rat)
+	case LVAL_FLOAT:
+		x.Float = v.Float
+	case LVAL_COMPLEX:
+		x.Complex = v.Complex
+	case LVAL_ERR, LVAL_COND:
+		x.Err = v.Err
+		x.ErrType = v.ErrType
+		x.ErrValue = lvalCopy(v.ErrValue)
+		x.Trace = v.Trace
+		x.exit = v.exit
+	case LVAL_SYM:
+		x.Sym = v.Sym
+	case LVAL_STR:
+		x.String = v.String
+	case LVAL_CHAR:
+		x.Char = v.Char
+	case LVAL_BOOL:
+		x.Bool = v.Bool
+	case LVAL_MAP:
+		x.Map = make(map[string]lmapEntry, len(v.Map))
+		for k, entry := range v.Map {
+			x.Map[k] = lmapEntry{key: lvalCopy(entry.key), val: lvalCopy(entry.val)}
+		}
+	case LVAL_VECTOR:
+		items := v.Vec.items()
+		for i := 0; i < len(items); i++ {
+			items[i] = lvalCopy(items[i])
+		}
+		x.Vec = lvecFrom(items)
+	case LVAL_SEXPR:
+		for i := 0; i < len(v.Cell); i++ {
+			x.Cell = append(x.Cell, lvalCopy(v.Cell[i]))
+		}
+	case LVAL_QEXPR:
+		for i := 0; i < len(v.Cell); i++ {
+			x.Cell = append(x.Cell, lvalCopy(v.Cell[i]))
+		}
+	}
+
+	return &x
+}
+
+// Check if two lvals are equal considering their types
+func lvalEq(firstArg *LVal, secondArg *LVal) bool {
+	//Numbers are equal when their values are, whatever kind they are, so 1 equals 1.0
+	if lvalIsNumber(firstArg) && lvalIsNumber(secondArg) {
+		return lvalNumCompare("==", firstArg, secondArg)
+	}
+
+	if firstArg.Type != secondArg.Type {
+		return false
+	}
+
+	switch firstArg.Type {
+	case LVAL_ERR:
+		return firstArg.Err == secondArg.Err
+	case LVAL_COND:
+		return firstArg.Err == secondArg.Err && firstArg.ErrType == secondArg.ErrType && lvalEq(firstArg.ErrValue, secondArg.ErrValue)
+	case LVAL_SYM:
+		return firstArg.Sym == secondArg.Sym
+	case LVAL_FUN:
+		if firstArg.Code != nil || secondArg.Code != nil {
+			return firstArg.Code == secondArg.Code
+		}
+
+		if firstArg.Builtin != nil || secondArg.Builtin != nil {
+			return reflect.ValueOf(firstArg.Builtin) == reflect.ValueOf(secondArg.Builtin)
+		}
+
+		return firstArg.Env == secondArg.Env && firstArg.Macro == secondArg.Macro && lvalEq(firstArg.Formals, secondArg.Formals) && lvalEq(firstArg.Body, secondArg.Body)
+	case LVAL_STR:
+		return firstArg.String == secondArg.String
+	case LVAL_CHAR:
+		return firstArg.Char == secondArg.Char
+	case LVAL_BOOL:
+		return firstArg.Bool == secondArg.Bool
+	case LVAL_NIL:
+		return true
+	case LVAL_MAP:
+		return lvalMapEq(firstArg, secondArg)
+	case LVAL_VECTOR:
+		return lvalVectorEq(firstArg, secondArg)
+	case LVAL_QEXPR:
+		fallthrough
+	case LVAL_SEXPR:
+		if len(firstArg.Cell) != len(secondArg.Cell) {
+			return false
+		}
+
+		for i := 0; i < len(firstArg.Cell); i++ {
+			if !lvalEq(firstArg.Cell[i], secondArg.Cell[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+//Takes a parser node(Lispy, Expression, or SExpression and turns it into an interpreter lval node)
+func lvalRead(node interface{}) *LVal {
+	var x *LVal
+	switch node.(type) {
+	// If it's an expression, then we either return an lval node that represents a number,
+	// and lval node that represents a symbol,
+	// or an lval node that represents an s expression(which is an lval node whose cell is the lval read of it's expression list)
+	// So really we are going all the way to the bottom depth of the sexpression recursively reading it into the lval node.
+	case *Expression:
+		node, _ := node.(*Expression)
+		if node.Number != nil {
+			x = node.Number.Value
+		} else if node.Sym != nil {
+			x = lvalReadSym(*node.Sym)
+		} else if node.String != nil {
+			x = lvalString(node.String.Value)
+		} else if node.Char != nil {
+			x = node.Char.Value
+		} else if node.SExpression != nil {
+			x = lvalSexpr()
+			lvalReadAll(x, node.SExpression.Expressions)
+		} else if node.QExpression != nil {
+			x = lvalQexpr()
+			lvalReadAll(x, node.QExpression.Expressions)
+		} else if node.Vector != nil {
+			// [a b ...] is a call to vector, so the elements get evaluated
+			x = lvalSexpr()
+			lvalAdd(x, lvalSym("vector"))
+			lvalReadAll(x, node.Vector.Expressions)
+
+			// (vector) on its own would give back vector, so [] is read as the empty vector
+			if len(x.Cell) == 1 {
+				x = lvalVector(lvecEmpty)
+			}
+		} else if node.MapLiteral != nil {
+			// #{k v ...} is a call to hash-map, so the keys and values get evaluated
+			x = lvalSexpr()
+			lvalAdd(x, lvalSym("hash-map"))
+			lvalReadAll(x, node.MapLiteral.Expressions)
+
+			// (hash-map) on its own would give back hash-map, so #{} is read as the empty map
+			if len(x.Cell) == 1 {
+				x = lvalMap(0)
+			}
+		} else if node.Tagged != nil {
+			// The reader macro may have given back a value that is shared, so it gets its own copy to hold the position
+			x = lvalCopy(node.Tagged.Value)
+		} else if node.Quote != nil {
+			// Like quasiquote, the quoted expression is wrapped in a q expression so it isn't evaluated
+			x = lvalReaderForm("quote", lvalAdd(lvalQexpr(), lvalRead(node.Quote)))
+		} else if node.Quasiquote != nil {
+			// The template gets wrapped in a q expression so that it isn't evaluated before quasiquote sees it
+			template := lvalRead(node.Quasiquote)
+			if template.Type == LVAL_SEXPR {
+				template.Type = LVAL_QEXPR
+			} else if template.Type != LVAL_QEXPR {
+				template = lvalAdd(lvalQexpr(), template)
+			}
+			x = lvalReaderForm("quasiquote", template)
+		} else if node.UnquoteSplicing != nil {
+			x = lvalReaderForm("unquote-splicing", lvalRead(node.UnquoteSplicing))
+		} else if node.Unquote != nil {
+			x = lvalReaderForm("unquote", lvalRead(node.Unquote))
+		}
+
+		x.Pos = node.Pos
+	// If it's the root node, we return the lvalRead of each of the expressions, recursively building the lval tree structure depth first
+	case *LISPY:
+		x = lvalSexpr()
+		node, _ := node.(*LISPY)
+		lvalReadAll(x, node.Expressions)
+	// Same as the sexpresison above
+	case *SExpression:
+		x = lvalSexpr()
+		node, _ := node.(*SExpression)
+		lvalReadAll(x, node.Expressions)
+	case *QExpression:
+		x = lvalQexpr()
+		node, _ := node.(*QExpression)
+		lvalReadAll(x, node.Expressions)
+	}
+
+	return x
+}
+
+// Read each of exprs onto the end of x's cells, leaving out any that were discarded with #_
+func lvalReadAll(x *LVal, exprs []*Expression) *LVal {
+	for i := 0; i < len(exprs); i++ {
+		if exprs[i].Discard == nil {
+			x.Cell = append(x.Cell, lvalRead(exprs[i]))
+		}
+	}
+
+	return x
+}
+
+// true, false and nil are read as themselves rather than as symbols, so they can't be redefined.
+// They are made fresh here since the reader sets their position.
+func lvalReadSym(sym string) *LVal {
+	switch sym {
+	case "true":
+		return &LVal{Type: LVAL_BOOL, Bool: true}
+	case "false":
+		return &LVal{Type: LVAL_BOOL, Bool: false}
+	case "nil":
+		return &LVal{Type: LVAL_NIL}
+	}
+
+	return lvalSym(sym)
+}
+
+// Everything counts as true except for false and nil
+func lvalTruthy(v *LVal) bool {
+	return !(v.Type == LVAL_NIL || v.Type == LVAL_BOOL && !v.Bool)
+}
+
+// Give back a copy of the error err with one more call added to the end of its trace. Errors
+// are shared like any other value, so the trace of err itself is left alone.
+func lvalErrFrame(err *LVal, name string, pos lexer.Position) *LVal {
+	x := *err
+	x.Trace = append(err.Trace[:len(err.Trace):len(err.Trace)], LFrame{Name: name, Pos: pos})
+
+	return &x
+}
+
+// The name of the function called by the s expression v, as it appears in a stack trace
+func lvalCallName(v *LVal) string {
+	if len(v.Cell) > 0 && v.Cell[0].Type == LVAL_SYM {
+		return v.Cell[0].Sym
+	}
+
+	return "lambda"
+}
+
+// Build the s expression that a reader shorthand like `x or ,x stands for
+func lvalReaderForm(name string, x *LVal) *LVal {
+	v := lvalSexpr()
+	lvalAdd(v, lvalSym(name))
+	lvalAdd(v, x)
+
+	return v
+}
+
+// Call a function that is represented by an lval
+func lvalCall(e *LEnv, f *LVal, a *LVal) *LVal {
+	//If it is a builtin function, return the result of running that function
+	if f.Builtin != nil {
+		return f.Builtin(e, a)
+	}
+
+	//Every call gets a fresh frame whose parent is the environment the function was made in
+	frame := lenvNew(f.Env)
+	formals := f.Formals.Cell
+
+	//Bind the arguments that were passed into the function
+	for len(a.Cell) > 0 {
+		if len(formals) == 0 {
+			return lvalErr("Function passed too many arguments")
+		}
+
+		sym := formals[0]
+		formals = formals[1:]
+
+		if sym.Sym == "&" {
+			if len(formals) != 1 {
+				return lvalErr("Symbol & not followed by a single symbol.")
+			}
+
+			lenvPut(frame, formals[0], builtinList(e, a))
+			formals = formals[1:]
+			break
+		}
+
+		val := lvalPop(a, 0)
+
+		lenvPut(frame, sym, val)
+	}
+
+	//If the only thing left is the variable argument, bind it to the empty list
+	if len(formals) > 0 && formals[0].Sym == "&" {
+		if len(formals) != 2 {
+			return lvalErr("Symbol & not followed by a single symbol.")
+		}
+
+		lenvPut(frame, formals[1], lvalQexpr())
+		formals = formals[2:]
+	}
+
+	if len(formals) == 0 {
+		return lvalTail(frame, lvalAs(f.Body, LVAL_SEXPR))
+	}
+
+	//Not everything was bound, so hand back a function that closes over what was and waits on the rest
+	rest := lvalQexpr()
+	rest.Cell = append(rest.Cell, formals...)
+
+	partial := lvalLambda(frame, rest, f.Body)
+	partial.Macro = f.Macro
+
+	return partial
+}
+
+// Evaluating the actual numberical result of the sexpression
+// This is a loop rather than plain recursion so that anything in tail position, like the last
+// form of a function body or the chosen branch of an if, reuses the current go stack frame
+func lvalEval(e *LEnv, v *LVal) *LVal {
+	return lvalEvalCall(e, v, nil)
+}
+
+// Evaluate v in e as the body of the call tail, which errors get added to the trace of. Tail
+// calls replace it as they go, so a trace only has the calls that are still waiting on a result.
+func lvalEvalCall(e *LEnv, v *LVal, tail *LVal) *LVal {
+	for {
+		var x *LVal
+
+		if v.Type == LVAL_SYM {
+			x = lenvGet(e, v)
+		} else if v.Type != LVAL_SEXPR {
+			// Otherwise, we just return the lval representation as it is since it is either an lval representing a number or a symbol or error already
+			x = v
+		} else {
+			// If this is a lval representation of an sexpression, we evaluate that
+			x = lvalEvalSexpr(e, v)
+
+			if x.Type == LVAL_TAIL {
+				if x.Sym != "" {
+					tail = x
+				}
+
+				e, v = x.Env, x.Body
+				continue
+			}
+		}
+
+		if x.Type == LVAL_ERR && tail != nil {
+			return lvalErrFrame(x, tail.Sym, tail.Pos)
+		}
+
+		return x
+	}
+}
+
+// Finish off a tail call that was returned somewhere other than lvalEval
+func lvalForce(x *LVal) *LVal {
+	if x.Type != LVAL_TAIL {
+		return x
+	}
+
+	if x.Sym != "" {
+		return lvalEvalCall(x.Env, x.Body, x)
+	}
+
+	return lvalEval(x.Env, x.Body)
+}
+
+func lvalEvalSexpr(e *LEnv, v *LVal) *LVal {
+	//If the cell has length 0, this is the empty list
+	if len(v.Cell) == 0 {
+		return v
+	}
+
+	// Evaluate Children
+	//The recursive case is a bit confusing but you basically just assume you have an lvalEval that works correctly and go through the children and evaulate them
+	// The interaction between lvalEvalSexpr and lvalEval is what recursively evaluates the structure and goes deep into the nested sexpressions, evaluating the deepst first
+	//The head gets evaluated first because if it is a macro, the rest of the children are handed over unevaluated
+	f := lvalEval(e, v.Cell[0])
+
+	if f.Type == LVAL_FUN && f.Macro {
+		a := lvalSexpr()
+		a.Cell = append(a.Cell, v.Cell[1:]...)
+
+		x := lvalMacroExpand(e, f, a)
+		if x.Type == LVAL_ERR {
+			return lvalErrFrame(x, lvalCallName(v), v.Pos)
+		}
+
+		return lvalTail(e, x)
+	}
+
+	//The code in v may be shared, so the evaluated children go into a new list rather than back into v
+	a := lvalSexpr()
+	a.Cell = make([]*LVal, 0, len(v.Cell)-1)
+	for i := 1; i < len(v.Cell); i++ {
+		a.Cell = append(a.Cell, lvalEval(e, v.Cell[i]))
+	}
+
+	//Check for an error type lval in the evaluation. If found, return that lval
+	if f.Type == LVAL_ERR {
+		return f
+	}
+
+	for i := 0; i < len(a.Cell); i++ {
+		if a.Cell[i].Type == LVAL_ERR {
+			return a.Cell[i]
+		}
+	}
+
+	//If the cell has length 1, it looks like (1) and we just want to return the
+	//lval representing the number 1
+	if len(v.Cell) == 1 {
+		return f
+	}
+
+	//Ensure that the first lval in the s-expression is a symbol
+	if f.Type != LVAL_FUN {
+		return lvalErr("First Element is not a function")
+	}
+
+	//Run the operation using the currnet lval and the input symbol
+	result := lvalCall(e, f, a)
+
+	//Errors from the call itself pick up this call in their trace. The body of a lambda is yet to
+	//be evaluated, so lvalEval is told which call it belongs to instead.
+	if result.Type == LVAL_ERR {
+		return lvalErrFrame(result, lvalCallName(v), v.Pos)
+	} else if result.Type == LVAL_TAIL && f.Builtin == nil {
+		result.Sym = lvalCallName(v)
+		result.Pos = v.Pos
+	}
+
+	return result
+}
+
+// Run a macro on its unevaluated arguments and turn whatever it returns back into code
+func lvalMacroExpand(e *LEnv, f *LVal, a *LVal) *LVal {
+	x := lvalForce(lvalCall(e, f, a))
+
+	if x.Type == LVAL_FUN && x.Macro {
+		return lvalErr("Macro was not passed enough arguments")
+	}
+
+	if x.Type == LVAL_QEXPR {
+		return lvalAs(x, LVAL_SEXPR)
+	}
+
+	return x
+}
+
+// Fill in a quasiquoted template, evaluating anything that is unquoted. The depth keeps track
+// of nested quasiquotes so that only the unquotes belonging to the outermost one get evaluated.
+// The reader turns the template of a quasiquote into a q expression, so when that template is
+// itself a quasiquote or unquote it has to be recognised as a q expression too.
+func lvalQuasiquote(e *LEnv, v *LVal, depth int) *LVal {
+	if v.Type != LVAL_SEXPR && v.Type != LVAL_QEXPR {
+		return v
+	}
+
+	if len(v.Cell) == 2 && v.Cell[0].Type == LVAL_SYM {
+		switch v.Cell[0].Sym {
+		case "unquote":
+			if depth == 1 {
+				return lvalEval(e, v.Cell[1])
+			}
+			depth--
+		case "unquote-splicing":
+			if depth == 1 {
+				return lvalErr("unquote-splicing can only be used inside of a list")
+			}
+			depth--
+		case "quasiquote":
+			depth++
+		}
+	}
+
+	x := LVal{Type: v.Type, Cell: make([]*LVal, 0)}
+
+	for i := 0; i < len(v.Cell); i++ {
+		c := v.Cell[i]
+
+		//Splices have to be handled by the enclosing list since they add several elements to it
+		if depth == 1 && c.Type == LVAL_SEXPR && len(c.Cell) == 2 && c.Cell[0].Type == LVAL_SYM && c.Cell[0].Sym == "unquote-splicing" {
+			spliced := lvalEval(e, c.Cell[1])
+
+			if spliced.Type == LVAL_ERR {
+				return spliced
+			}
+
+			if spliced.Type != LVAL_QEXPR && spliced.Type != LVAL_SEXPR {
+				return lvalErr("unquote-splicing must be given a q expression")
+			}
+
+			x.Cell = append(x.Cell, spliced.Cell...)
+			continue
+		}
+
+		y := lvalQuasiquote(e, c, depth)
+		if y.Type == LVAL_ERR {
+			return y
+		}
+
+		x.Cell = append(x.Cell, y)
+	}
+
+	return &x
+}
@@ -0,0 +1,293 @@
+package lispy
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains hash maps. A map is written #{key value ...}, which reads as a call   //
+// to hash-map, so the keys and values are evaluated like any other arguments. Maps never  //
+// change once made, so assoc, dissoc and merge copy the map they are given and change the //
+// copy. Keys are looked up by a string built from their value, which makes equal keys     //
+// like 1 and 1.0 the same key.                                                             //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// One key and its value, kept together since the go map is indexed by the key's hash string
+type lmapEntry struct {
+	key *LVal
+	val *LVal
+}
+
+// Make an empty map with room for n keys
+func lvalMap(n int) *LVal {
+	val := LVal{Type: LVAL_MAP, Map: make(map[string]lmapEntry, n)}
+	return &val
+}
+
+// Make a new map holding everything in m, with room for n more
+func lvalMapCopy(m *LVal, n int) *LVal {
+	x := lvalMap(len(m.Map) + n)
+	for k, entry := range m.Map {
+		x.Map[k] = entry
+	}
+
+	return x
+}
+
+// Build the string a key is found by. Two values that lvalEq says are equal give the same
+// string, apart from numbers, which are matched by their exact value. Functions and errors
+// can't be keys.
+func lvalHashKey(v *LVal) (string, bool) {
+	var b strings.Builder
+	if !lvalWriteHashKey(&b, v) {
+		return "", false
+	}
+
+	return b.String(), true
+}
+
+func lvalWriteHashKey(b *strings.Builder, v *LVal) bool {
+	switch v.Type {
+	case LVAL_INT, LVAL_BIGINT, LVAL_RAT, LVAL_FLOAT:
+		b.WriteString("n" + lvalExactString(v))
+	case LVAL_COMPLEX:
+		if imag(v.Complex) == 0 {
+			b.WriteString("n" + lvalExactString(lvalFloat(real(v.Complex))))
+		} else {
+			b.WriteString("c" + lvalNumString(v))
+		}
+	case LVAL_STR:
+		b.WriteString("s" + strconv.Quote(v.String))
+	case LVAL_CHAR:
+		b.WriteString("r" + strconv.QuoteRune(v.Char))
+	case LVAL_SYM:
+		b.WriteString("y" + v.Sym)
+	case LVAL_BOOL:
+		b.WriteString("b" + strconv.FormatBool(v.Bool))
+	case LVAL_NIL:
+		b.WriteString("nil")
+	case LVAL_QEXPR, LVAL_SEXPR:
+		start, end := "{", "}"
+		if v.Type == LVAL_SEXPR {
+			start, end = "(", ")"
+		}
+
+		b.WriteString(start)
+		for i := 0; i < len(v.Cell); i++ {
+			if !lvalWriteHashKey(b, v.Cell[i]) {
+				return false
+			}
+			b.WriteString(" ")
+		}
+		b.WriteString(end)
+	case LVAL_VECTOR:
+		b.WriteString("[")
+		for i := 0; i < v.Vec.count; i++ {
+			if !lvalWriteHashKey(b, v.Vec.nth(i)) {
+				return false
+			}
+			b.WriteString(" ")
+		}
+		b.WriteString("]")
+	case LVAL_MAP:
+		//The entries are written in order of their keys so that equal maps give the same string
+		b.WriteString("#{")
+		for _, k := range lvalMapKeys(v) {
+			b.WriteString(k + " ")
+			if !lvalWriteHashKey(b, v.Map[k].val) {
+				return false
+			}
+			b.WriteString(" ")
+		}
+		b.WriteString("}")
+	default:
+		return false
+	}
+
+	return true
+}
+
+// A real number's exact value as a fraction, so 0.5 and 1/2 come out the same
+func lvalExactString(v *LVal) string {
+	switch v.Type {
+	case LVAL_INT, LVAL_BIGINT, LVAL_RAT:
+		return lvalToRat(v).RatString()
+	}
+
+	if math.IsInf(v.Float, 0) || math.IsNaN(v.Float) {
+		return lvalNumString(v)
+	}
+
+	return new(big.Rat).SetFloat64(v.Float).RatString()
+}
+
+// The hash strings of a map's keys in sorted order, which is the order maps are printed in
+func lvalMapKeys(m *LVal) []string {
+	keys := make([]string, 0, len(m.Map))
+	for k := range m.Map {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Check that a map holds the same keys as another, with equal values
+func lvalMapEq(x *LVal, y *LVal) bool {
+	if len(x.Map) != len(y.Map) {
+		return false
+	}
+
+	for k, entry := range x.Map {
+		other, ok := y.Map[k]
+		if !ok || !lvalEq(entry.val, other.val) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add each key and value pair in kvs to the map m, which must be freshly made
+func lvalMapPut(name string, m *LVal, kvs []*LVal) *LVal {
+	if len(kvs)%2 != 0 {
+		return lvalErr("Function '" + name + "' must be given keys and values in pairs")
+	}
+
+	for i := 0; i < len(kvs); i += 2 {
+		k, ok := lvalHashKey(kvs[i])
+		if !ok {
+			return lvalErr("Function '" + name + "' cannot use a function or error as a key")
+		}
+		m.Map[k] = lmapEntry{key: kvs[i], val: kvs[i+1]}
+	}
+
+	return m
+}
+
+// (hash-map key value ...) makes a map. #{key value ...} reads as a call to it.
+func builtinHashMap(e *LEnv, a *LVal) *LVal {
+	return lvalMapPut("hash-map", lvalMap(len(a.Cell)/2), a.Cell)
+}
+
+// (get m key) gives back the value of key in m, or nil if it isn't there. (get m key default)
+// gives back default instead of nil.
+func builtinGet(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 && len(a.Cell) != 3 {
+		return lvalErr("Function 'get' must be given a map, a key and optionally a default")
+	}
+
+	if a.Cell[0].Type != LVAL_MAP {
+		return lvalErr("Function 'get' must be given a map as its first argument")
+	}
+
+	if k, ok := lvalHashKey(a.Cell[1]); ok {
+		if entry, ok := a.Cell[0].Map[k]; ok {
+			return entry.val
+		}
+	}
+
+	if len(a.Cell) == 3 {
+		return a.Cell[2]
+	}
+
+	return lvalNil()
+}
+
+// (assoc m key value ...) gives back a copy of m with each key set to its value. Vectors are
+// handed over to lvalVectorAssoc.
+func builtinAssoc(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) > 0 && a.Cell[0].Type == LVAL_VECTOR {
+		return lvalVectorAssoc(a)
+	}
+
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_MAP {
+		return lvalErr("Function 'assoc' must be given a map or vector as its first argument")
+	}
+
+	return lvalMapPut("assoc", lvalMapCopy(a.Cell[0], len(a.Cell)/2), a.Cell[1:])
+}
+
+// (dissoc m key ...) gives back a copy of m without the given keys
+func builtinDissoc(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_MAP {
+		return lvalErr("Function 'dissoc' must be given a map as its first argument")
+	}
+
+	x := lvalMapCopy(a.Cell[0], 0)
+	for i := 1; i < len(a.Cell); i++ {
+		if k, ok := lvalHashKey(a.Cell[i]); ok {
+			delete(x.Map, k)
+		}
+	}
+
+	return x
+}
+
+// (keys m) gives back a q expression of the keys in m, in the order the map prints them
+func builtinKeys(e *LEnv, a *LVal) *LVal {
+	return lvalMapList(a, "keys", func(entry lmapEntry) *LVal { return entry.key })
+}
+
+// (vals m) gives back a q expression of the values in m, in the same order as keys
+func builtinVals(e *LEnv, a *LVal) *LVal {
+	return lvalMapList(a, "vals", func(entry lmapEntry) *LVal { return entry.val })
+}
+
+func lvalMapList(a *LVal, name string, part func(lmapEntry) *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_MAP {
+		return lvalErr("Function '" + name + "' must be given a single map")
+	}
+
+	m := a.Cell[0]
+	x := lvalQexpr()
+	for _, k := range lvalMapKeys(m) {
+		lvalAdd(x, part(m.Map[k]))
+	}
+
+	return x
+}
+
+// (contains? m key) checks whether m has a value for key
+func builtinContains(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 || a.Cell[0].Type != LVAL_MAP {
+		return lvalErr("Function 'contains?' must be given a map and a key")
+	}
+
+	k, ok := lvalHashKey(a.Cell[1])
+	if !ok {
+		return lvalBool(false)
+	}
+
+	_, ok = a.Cell[0].Map[k]
+	return lvalBool(ok)
+}
+
+// (merge m ...) gives back a map with everything in each of the maps, where later maps win
+// when they have the same key
+func builtinMerge(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 {
+		return lvalErr("Function 'merge' must be given at least one map")
+	}
+
+	n := 0
+	for i := 0; i < len(a.Cell); i++ {
+		if a.Cell[i].Type != LVAL_MAP {
+			return lvalErr("Function 'merge' must only be given maps")
+		}
+		n += len(a.Cell[i].Map)
+	}
+
+	x := lvalMapCopy(a.Cell[0], n)
+	for i := 1; i < len(a.Cell); i++ {
+		for k, entry := range a.Cell[i].Map {
+			x.Map[k] = entry
+		}
+	}
+
+	return x
+}
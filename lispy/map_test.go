@@ -0,0 +1,20 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestMaps(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(def {m} #{"b" 2 "a" (+ 1 0)}) (list m (get m "a") (get m "z") (get m "z" 0) (keys m) (vals m))`, `{#{"a" 1 "b" 2} 1 nil 0 {"a" "b"} {1 2}}`},
+		{`(def {m} #{"a" 1 "b" 2}) (list (assoc m "c" 3) (dissoc m "a") (assoc m "a" 10) m)`, `{#{"a" 1 "b" 2 "c" 3} #{"b" 2} #{"a" 10 "b" 2} #{"a" 1 "b" 2}}`},
+		{`(list (contains? #{1 2} 1.0) (contains? #{1 2} 3) (merge #{"a" 1} #{"a" 10 "b" 2}) (== #{"a" 1 "b" 2} #{"b" 2 "a" 1.0}))`, `{true false #{"a" 10 "b" 2} true}`},
+		{`(list (get #{#{1 2} "m"} #{1 2}) (get #{{1 2} "q"} {1 2}) (get #{#\a 1 'x 2 nil 3 true 4} nil) (get #{(/ 1 2) "half"} 0.5))`, `{"m" "q" 3 "half"}`},
+		{`(list #{} #{#_1} (count #{}) (assoc #{} 1 2) (keys #{}))`, "{#{} #{} 0 #{1 2} {}}"},
+		{`(count #{1 2 3 4})`, "2"},
+		{`#{1 2 3}`, "error: Function 'hash-map' must be given keys and values in pairs"},
+		{`(get #{})`, "error: Function 'get' must be given a map, a key and optionally a default"},
+		{`(get 1 2)`, "error: Function 'get' must be given a map as its first argument"},
+		{`(assoc #{} "k")`, "error: Function 'assoc' must be given keys and values in pairs"},
+	})
+}
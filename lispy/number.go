@@ -0,0 +1,414 @@
+package lispy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the numeric tower. Integers are int64s until they overflow, when they //
+// become big integers, and dividing integers gives an exact rational. Floats only come     //
+// from writing one or from math on one. Whenever two kinds of number meet, the result is  //
+// the kind furthest up the tower: integer, big integer, rational, float, then complex.     //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// How far up the tower a number is, or -1 for anything that isn't a number
+func lvalNumRank(v *LVal) int {
+	switch v.Type {
+	case LVAL_INT:
+		return 0
+	case LVAL_BIGINT:
+		return 1
+	case LVAL_RAT:
+		return 2
+	case LVAL_FLOAT:
+		return 3
+	case LVAL_COMPLEX:
+		return 4
+	}
+
+	return -1
+}
+
+func lvalIsNumber(v *LVal) bool {
+	return lvalNumRank(v) >= 0
+}
+
+// Every number but a complex one is real, and only real numbers can be ordered
+func lvalIsReal(v *LVal) bool {
+	return lvalIsNumber(v) && v.Type != LVAL_COMPLEX
+}
+
+// Read a number literal. It may start with a sign and have _ between digits. Integers can be
+// written in hex, binary or octal with a 0x, 0b or 0o prefix. Anything else ending in i is
+// complex, anything with a decimal point or an exponent is a float, and everything else is an
+// integer however big it is. The lval is made fresh since the reader sets its position.
+func lvalReadNumber(lit string) (*LVal, error) {
+	malformed := fmt.Errorf("malformed number %q", lit)
+
+	digits := strings.TrimLeft(lit, "+-")
+	if len(lit)-len(digits) > 1 {
+		return nil, malformed
+	}
+
+	if len(digits) > 2 && digits[0] == '0' && strings.ContainsRune("xXbBoO", rune(digits[1])) {
+		//math/big understands the prefixes and the underscores in them itself
+		n, ok := new(big.Int).SetString(lit, 0)
+		if !ok {
+			return nil, malformed
+		}
+		return lvalReadInt(n), nil
+	}
+
+	//Go would also read things like Inf, NaN and hex floats, which aren't lispy numbers
+	if strings.IndexFunc(digits, lvalNotNumChar) >= 0 || strings.Contains(strings.TrimSuffix(digits, "i"), "i") {
+		return nil, malformed
+	}
+
+	if strings.HasSuffix(lit, "i") {
+		c, err := strconv.ParseComplex(lit, 128)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("number %q is out of range", lit)
+		} else if err != nil {
+			return nil, malformed
+		}
+
+		return &LVal{Type: LVAL_COMPLEX, Complex: c}, nil
+	}
+
+	if strings.ContainsAny(lit, ".eE") {
+		f, err := strconv.ParseFloat(lit, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("number %q is out of range", lit)
+		} else if err != nil {
+			return nil, malformed
+		}
+
+		return &LVal{Type: LVAL_FLOAT, Float: f}, nil
+	}
+
+	//A leading 0 would make math/big read the number as octal, so underscores are checked here
+	//and then taken out
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' && (i == 0 || i == len(digits)-1 || digits[i-1] == '_') {
+			return nil, malformed
+		}
+	}
+
+	n, ok := new(big.Int).SetString(strings.Replace(lit, "_", "", -1), 10)
+	if !ok {
+		return nil, malformed
+	}
+
+	return lvalReadInt(n), nil
+}
+
+func lvalNotNumChar(r rune) bool {
+	return !strings.ContainsRune("0123456789._eE+-i", r)
+}
+
+func lvalReadInt(n *big.Int) *LVal {
+	if n.IsInt64() {
+		return &LVal{Type: LVAL_INT, Int: n.Int64()}
+	}
+
+	return &LVal{Type: LVAL_BIGINT, Big: n}
+}
+
+// Floats always print with a decimal point or an exponent so they can be told apart from
+// integers, and rationals print as numerator/denominator
+func lvalNumString(v *LVal) string {
+	switch v.Type {
+	case LVAL_INT:
+		return strconv.FormatInt(v.Int, 10)
+	case LVAL_BIGINT:
+		return v.Big.String()
+	case LVAL_RAT:
+		return v.Rat.String()
+	case LVAL_COMPLEX:
+		//Go wraps complex numbers in brackets, which would read back as an s expression
+		return strings.Trim(fmt.Sprint(v.Complex), "()")
+	}
+
+	s := fmt.Sprint(v.Float)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+}
+
+// Conversions up the tower. The integer kinds share their value rather than copying it, which
+// is fine since the math/big functions below always build a new one for their result.
+
+func lvalToBigInt(v *LVal) *big.Int {
+	if v.Type == LVAL_BIGINT {
+		return v.Big
+	}
+
+	return big.NewInt(v.Int)
+}
+
+func lvalToRat(v *LVal) *big.Rat {
+	switch v.Type {
+	case LVAL_RAT:
+		return v.Rat
+	case LVAL_BIGINT:
+		return new(big.Rat).SetInt(v.Big)
+	}
+
+	return new(big.Rat).SetInt64(v.Int)
+}
+
+func lvalToFloat(v *LVal) float64 {
+	switch v.Type {
+	case LVAL_FLOAT:
+		return v.Float
+	case LVAL_RAT:
+		f, _ := v.Rat.Float64()
+		return f
+	case LVAL_BIGINT:
+		f, _ := new(big.Float).SetInt(v.Big).Float64()
+		return f
+	}
+
+	return float64(v.Int)
+}
+
+func lvalToComplex(v *LVal) complex128 {
+	if v.Type == LVAL_COMPLEX {
+		return v.Complex
+	}
+
+	return complex(lvalToFloat(v), 0)
+}
+
+// Give back x op y for any two numbers, where op is one of + - * /
+func lvalNumOp(op string, x *LVal, y *LVal) *LVal {
+	rank := lvalNumRank(x)
+	if r := lvalNumRank(y); r > rank {
+		rank = r
+	}
+
+	switch rank {
+	case 0:
+		return lvalIntOp(op, x.Int, y.Int)
+	case 1:
+		a, b := lvalToBigInt(x), lvalToBigInt(y)
+
+		switch op {
+		case "+":
+			return lvalBigInt(new(big.Int).Add(a, b))
+		case "-":
+			return lvalBigInt(new(big.Int).Sub(a, b))
+		case "*":
+			return lvalBigInt(new(big.Int).Mul(a, b))
+		}
+
+		if b.Sign() == 0 {
+			return lvalErr("Cannot divide by 0")
+		}
+		return lvalRat(new(big.Rat).SetFrac(a, b))
+	case 2:
+		a, b := lvalToRat(x), lvalToRat(y)
+
+		switch op {
+		case "+":
+			return lvalRat(new(big.Rat).Add(a, b))
+		case "-":
+			return lvalRat(new(big.Rat).Sub(a, b))
+		case "*":
+			return lvalRat(new(big.Rat).Mul(a, b))
+		}
+
+		if b.Sign() == 0 {
+			return lvalErr("Cannot divide by 0")
+		}
+		return lvalRat(new(big.Rat).Quo(a, b))
+	case 4:
+		a, b := lvalToComplex(x), lvalToComplex(y)
+
+		switch op {
+		case "+":
+			return lvalComplex(a + b)
+		case "-":
+			return lvalComplex(a - b)
+		case "*":
+			return lvalComplex(a * b)
+		}
+
+		if b == 0 {
+			return lvalErr("Cannot divide by 0")
+		}
+		return lvalComplex(a / b)
+	}
+
+	a, b := lvalToFloat(x), lvalToFloat(y)
+
+	switch op {
+	case "+":
+		return lvalFloat(a + b)
+	case "-":
+		return lvalFloat(a - b)
+	case "*":
+		return lvalFloat(a * b)
+	}
+
+	if b == 0 {
+		return lvalErr("Cannot divide by 0")
+	}
+	return lvalFloat(a / b)
+}
+
+// Integer arithmetic, moving over to big integers whenever the result doesn't fit in an int64
+func lvalIntOp(op string, a int64, b int64) *LVal {
+	switch op {
+	case "+":
+		if s := a + b; (s > a) == (b > 0) {
+			return lvalInt(s)
+		}
+		return lvalBigInt(new(big.Int).Add(big.NewInt(a), big.NewInt(b)))
+	case "-":
+		if d := a - b; (d < a) == (b > 0) {
+			return lvalInt(d)
+		}
+		return lvalBigInt(new(big.Int).Sub(big.NewInt(a), big.NewInt(b)))
+	case "*":
+		if a == 0 || b == 0 {
+			return lvalInt(0)
+		}
+		if p := a * b; p/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return lvalInt(p)
+		}
+		return lvalBigInt(new(big.Int).Mul(big.NewInt(a), big.NewInt(b)))
+	}
+
+	if b == 0 {
+		return lvalErr("Cannot divide by 0")
+	}
+	if a%b == 0 && !(a == math.MinInt64 && b == -1) {
+		return lvalInt(a / b)
+	}
+	return lvalRat(big.NewRat(a, b))
+}
+
+func lvalNumNegate(x *LVal) *LVal {
+	switch x.Type {
+	case LVAL_INT:
+		if x.Int == math.MinInt64 {
+			return lvalBigInt(new(big.Int).Neg(big.NewInt(x.Int)))
+		}
+		return lvalInt(-x.Int)
+	case LVAL_BIGINT:
+		return lvalBigInt(new(big.Int).Neg(x.Big))
+	case LVAL_RAT:
+		return lvalRat(new(big.Rat).Neg(x.Rat))
+	case LVAL_COMPLEX:
+		return lvalComplex(-x.Complex)
+	}
+
+	return lvalFloat(-x.Float)
+}
+
+// Compare two numbers with one of < > <= >= ==. Exact numbers are compared exactly, and so is a
+// float against an exact number, since turning a big exact number into a float can round it.
+// Complex numbers only have ==, so they should be ruled out before asking for anything else.
+func lvalNumCompare(op string, x *LVal, y *LVal) bool {
+	rank := lvalNumRank(x)
+	if r := lvalNumRank(y); r > rank {
+		rank = r
+	}
+
+	if rank == 4 {
+		return op == "==" && lvalToComplex(x) == lvalToComplex(y)
+	}
+
+	var c int
+	switch {
+	case rank == 0:
+		if x.Int < y.Int {
+			c = -1
+		} else if x.Int > y.Int {
+			c = 1
+		}
+	case rank == 1:
+		c = lvalToBigInt(x).Cmp(lvalToBigInt(y))
+	case rank == 2:
+		c = lvalToRat(x).Cmp(lvalToRat(y))
+	case x.Type == LVAL_FLOAT && y.Type == LVAL_FLOAT:
+		//NaN isn't less than, greater than or equal to anything, so floats can't go through c
+		a, b := x.Float, y.Float
+
+		switch op {
+		case "<":
+			return a < b
+		case ">":
+			return a > b
+		case "<=":
+			return a <= b
+		case ">=":
+			return a >= b
+		}
+		return a == b
+	default:
+		f := x
+		if y.Type == LVAL_FLOAT {
+			f = y
+		}
+
+		switch {
+		case math.IsNaN(f.Float):
+			return false
+		case math.IsInf(f.Float, 0):
+			//Infinity is beyond every exact number
+			c = 1
+			if f.Float < 0 {
+				c = -1
+			}
+			if f == y {
+				c = -c
+			}
+		default:
+			c = lvalExactRat(x).Cmp(lvalExactRat(y))
+		}
+	}
+
+	switch op {
+	case "<":
+		return c < 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case ">=":
+		return c >= 0
+	}
+	return c == 0
+}
+
+// The exact value of a real number, including a finite float
+func lvalExactRat(v *LVal) *big.Rat {
+	if v.Type == LVAL_FLOAT {
+		return new(big.Rat).SetFloat64(v.Float)
+	}
+
+	return lvalToRat(v)
+}
+
+// Convert a number to an int64 if it is whole and fits, for handing to go code
+func lvalToInt64(v *LVal) (int64, bool) {
+	switch v.Type {
+	case LVAL_INT:
+		return v.Int, true
+	case LVAL_FLOAT:
+		if v.Float == math.Trunc(v.Float) && v.Float >= math.MinInt64 && v.Float < math.MaxInt64 {
+			return int64(v.Float), true
+		}
+	}
+
+	return 0, false
+}
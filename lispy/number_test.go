@@ -0,0 +1,69 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestNumericTower(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list (+ 9223372036854775807 1) (- -9223372036854775808 1) (* 4294967296 4294967296) (- (+ 9223372036854775807 1) 1))`, "{9223372036854775808 -9223372036854775809 18446744073709551616 9223372036854775807}"},
+		{`(list (/ 1 3) (/ 6 3) (/ -2 4) (+ (/ 1 3) (/ 2 3)) (* (/ 2 3) 3) (- (/ 1 2)) (/ 1 (/ 1 3)))`, "{1/3 2 -1/2 1 2 -1/2 3}"},
+		{`(list (+ 1 0.5) (* (/ 1 2) 0.5) (- 1.5) 42.0 1e21 (/ 1 4.0))`, "{1.5 0.25 -1.5 42.0 1e+21 0.25}"},
+		{`(list (- 5) (- 0) (* 3) (+ 2) (- (- 0 9223372036854775807 1)))`, "{-5 0 3 2 9223372036854775808}"},
+		{`(list (/ 1 0) (/ 1.0 0) (/ (/ 1 2) 0))`, "error: Cannot divide by 0"},
+		{`(+ 1 "a")`, "error: Cannot operate on a non number"},
+		{`(-)`, "builtin"},
+	})
+}
+
+func TestComplexNumbers(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list 3+4i (+ 1+2i 3-1i) (* 2i 2i) (/ 1 2i) (- 3-4.5i) (+ 1+2i (/ 1 2)) (* 1i 1.5))`, "{3+4i 4+1i -4+0i 0-0.5i -3+4.5i 1.5+2i 0+1.5i}"},
+		{`(list (== 3+0i 3) (== 1+1i 1+1i) (== 1i 1))`, "{true true false}"},
+		{`(< 1i 2)`, "error: Cannot order complex numbers"},
+		{`(list (cmath/abs 3+4i) (cmath/sqrt -1) (cmath/phase 1i) (cmath/real 3) (cmath/imag 2i))`, "{5.0 0+1i 1.5707963267948966 3.0 2.0}"},
+	})
+}
+
+func TestNumberLiterals(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list -5 +5 -1.5 .5 +.5 1e3 -2.5E-2 1.5e+3 -0 -0.0)`, "{-5 5 -1.5 0.5 0.5 1000.0 -0.025 1500.0 0 -0.0}"},
+		{`(list 1_000_000 0xff -0x_1F 0b1010 0o17 0x7fffffffffffffff 0x8000000000000000)`, "{1000000 255 -31 10 15 9223372036854775807 9223372036854775808}"},
+		{`(list -3-4i 1e1+2i (- 5 -3) (* -1 -99999999999999999999))`, "{-3-4i 10+2i 8 99999999999999999999}"},
+	})
+}
+
+func TestNumberComparisons(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(== 9007199254740993 9007199254740992.0)`, "false"},
+		{`(== 9007199254740992 9007199254740992.0)`, "true"},
+		{`(< 9007199254740992.0 9007199254740993)`, "true"},
+		{`(list (== 1 1.0) (< (/ 1 3) 0.3333333333333333) (> (/ 1 3) 0.3333333333333333))`, "{true false true}"},
+		{`(list (== 0.1 (/ 1 10)) (> 0.1 (/ 1 10)))`, "{false true}"},
+		{`(list (> (* 1e300 1e300) 1) (> (* 99999999999999999999 1e300 1e300) math/inf))`, "{true false}"},
+		{`(list (< (- math/inf) -99999999999999999999) (== math/nan 1) (< math/nan 1) (>= 1 math/nan))`, "{true false false false}"},
+		{`(== 1)`, "error: Function '==' must be given two arguments"},
+		{`(< 1 2 3)`, "error: Function '<' must be given two arguments"},
+	})
+}
+
+func TestNumberLiteralsOutOfRange(t *testing.T) {
+	tests := map[string]string{
+		`1.0e400`: `number "1.0e400" is out of range`,
+		`-1e400`:  `number "-1e400" is out of range`,
+		`1e400i`:  `number "1e400i" is out of range`,
+		`1.2.3`:   `malformed number "1.2.3"`,
+		`1__000`:  `malformed number "1__000"`,
+		`0xfg`:    `malformed number "0xfg"`,
+		`1e`:      `malformed number "1e"`,
+	}
+
+	for src, want := range tests {
+		_, err := New().Parse(src)
+
+		perr, ok := err.(*ParseError)
+		if !ok || perr.Message != want {
+			t.Errorf("%s: got %v, want %s", src, err, want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package lispy
+
+import (
+	"os"
+	"strings"
+
+	"github.com/alecthomas/participle"
+	"github.com/alecthomas/participle/lexer"
+	"github.com/alecthomas/participle/lexer/ebnf"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the parsing construct in participle. It takes lispy source text and   //
+// turns it into participle nodes which then get translated into interpreter constructs     //
+// using functions inside of lval.go                                                        //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+//This grammar can be cleaned up. I should probably do that at some point if I'm ever going to add this as a resume project or something.
+// As a note, I can make lowercase be its own range, upper case be its own range, and the symbols have their own range and then construct
+// Symbol and String out of those constructs.
+var lispyLexer = lexer.Must(ebnf.New(`
+		digit = "0"…"9" . 
+		String = "\"" { "\\" anychar | strchar } "\"" .
+		anychar = "\x00"…"\U0010ffff" .
+		strchar = "\x00"…"\U0010ffff"-"\""-"\\" .
+		Number = ["+" | "-"] ("." | digit) { "a"…"z" | "A"…"Z" | digit | "." | "_" | "+" | "-" } .
+        Symbol = ("a"…"z" | "A"…"Z" | "0"…"9" | "+" | "-" | "*" | "/" | "_" | "=" | "<" | ">" | "!" | "&" | "?") { "a"…"z" | "A"…"Z" | "0"…"9" | "+" | "-" | "*" | "/" | "_" | "=" | "<" | ">" | "!" | "&" | "?"} .
+        Char = "#\\" anychar { "a"…"z" | "A"…"Z" | "0"…"9" } .
+        Whitespace = " " | "\t" | "\n" | "\r" .
+        Comment = ";" { "\x00"…"\U0010ffff"-"\n" } .
+        BlockComment = "#|" blockrest .
+        blockrest = { blockchar | "#" ["|" blockrest] } "|" {"|"} ("#" | blockrest) .
+        blockchar = "\x00"…"\U0010ffff"-"|"-"#" .
+        Discard = "#_" .
+        Tag = "#" ("a"…"z" | "A"…"Z") { "a"…"z" | "A"…"Z" | "0"…"9" | "-" | "_" | "/" } .
+        MapOpen = "#{" .
+        Punct = "(" | ")" | "{" | "}" | "[" | "]" .
+        Quote = "'" .
+        Quasiquote = "\x60" .
+        UnquoteSplicing = ",@" .
+        Unquote = "," .
+                `))
+
+type LISPY struct {
+	Expressions []*Expression `@@*`
+}
+
+type QExpression struct {
+	Pos         lexer.Position
+	Expressions []*Expression ` "{" @@* "}"`
+}
+
+type SExpression struct {
+	Pos         lexer.Position
+	Expressions []*Expression ` "(" @@* ")"`
+}
+
+type Vector struct {
+	Pos         lexer.Position
+	Expressions []*Expression ` "[" @@* "]"`
+}
+
+type MapLiteral struct {
+	Pos         lexer.Position
+	Expressions []*Expression ` "#{" @@* "}"`
+}
+
+type Expression struct {
+	// Filled in by participle with where the expression starts, so errors can point back at it
+	Pos lexer.Position
+
+	Number      *lispyNumber `      @Number `
+	Sym         *string      `|     @Symbol `
+	String      *lispyString `|     @String `
+	Char        *lispyChar   `|     @Char `
+	SExpression *SExpression `|     @@ `
+	QExpression *QExpression `|     @@ `
+	Vector      *Vector      `|     @@ `
+	MapLiteral  *MapLiteral  `|     @@ `
+	Tagged      *lispyTagged `|     @@ `
+
+	// Reader shorthands for quote, quasiquote, unquote-splicing and unquote
+	Quote           *Expression `|     "'" @@ `
+	Quasiquote      *Expression "|     \"`\" @@ "
+	UnquoteSplicing *Expression `|     ",@" @@ `
+	Unquote         *Expression `|     "," @@ `
+
+	// #_ comments out the expression after it, which is parsed and then left out by lvalReadAll
+	Discard *Expression `|     "#_" @@ `
+}
+
+// Literals that can't be read keep hold of the error in Err rather than failing the capture,
+// because participle would only report that as the literal being an unexpected token. Once
+// parsing is done lispyReadLiterals finds them.
+
+// A number literal, read into an integer, big integer, float or complex number by how it is
+// written
+type lispyNumber struct {
+	Pos   lexer.Position
+	Value *LVal
+	Err   error
+}
+
+func (n *lispyNumber) Capture(values []string) error {
+	n.Value, n.Err = lvalReadNumber(strings.Join(values, ""))
+	return nil
+}
+
+// A string literal with its quotes taken off and its escapes turned into the characters they
+// stand for
+type lispyString struct {
+	Pos   lexer.Position
+	Value string
+	Err   error
+}
+
+func (s *lispyString) Capture(values []string) error {
+	s.Value, s.Err = lvalUnescapeStr(strings.Join(values, ""))
+	return nil
+}
+
+// A character literal like #\a, #\newline or #\x3bb
+type lispyChar struct {
+	Pos   lexer.Position
+	Value *LVal
+	Err   error
+}
+
+func (c *lispyChar) Capture(values []string) error {
+	c.Value, c.Err = lvalReadChar(strings.Join(values, ""))
+	return nil
+}
+
+// A tagged literal like #date"2024-01-01", which is read by handing the string to the reader
+// macro registered for its tag. Value is filled in by lispyReadLiterals once parsing is done.
+type lispyTagged struct {
+	Pos   lexer.Position
+	Tag   string       `@Tag`
+	Text  *lispyString `@String`
+	Value *LVal
+}
+
+// Finish reading the literals in exprs, running the reader macros in e for tagged literals and
+// giving back an error for the first literal that couldn't be read. With no e, tagged literals
+// are only checked, and the ones in discarded expressions never get their reader macro run.
+func lispyReadLiterals(e *LEnv, exprs []*Expression) error {
+	for i := 0; i < len(exprs); i++ {
+		x := exprs[i]
+		var err error
+
+		switch {
+		case x.Number != nil && x.Number.Err != nil:
+			return lexer.Errorf(x.Pos, "%s", x.Number.Err)
+		case x.String != nil && x.String.Err != nil:
+			return lexer.Errorf(x.Pos, "%s", x.String.Err)
+		case x.Char != nil && x.Char.Err != nil:
+			return lexer.Errorf(x.Pos, "%s", x.Char.Err)
+		case x.Tagged != nil && x.Tagged.Text.Err != nil:
+			return lexer.Errorf(x.Pos, "%s", x.Tagged.Text.Err)
+		case x.Tagged != nil && e != nil:
+			x.Tagged.Value, err = lvalReadTagged(e, x.Tagged.Tag, x.Tagged.Text.Value)
+			if err != nil {
+				return lexer.Errorf(x.Pos, "%s", err)
+			}
+		case x.SExpression != nil:
+			err = lispyReadLiterals(e, x.SExpression.Expressions)
+		case x.QExpression != nil:
+			err = lispyReadLiterals(e, x.QExpression.Expressions)
+		case x.Vector != nil:
+			err = lispyReadLiterals(e, x.Vector.Expressions)
+		case x.MapLiteral != nil:
+			err = lispyReadLiterals(e, x.MapLiteral.Expressions)
+		case x.Quote != nil:
+			err = lispyReadLiterals(e, []*Expression{x.Quote})
+		case x.Quasiquote != nil:
+			err = lispyReadLiterals(e, []*Expression{x.Quasiquote})
+		case x.UnquoteSplicing != nil:
+			err = lispyReadLiterals(e, []*Expression{x.UnquoteSplicing})
+		case x.Unquote != nil:
+			err = lispyReadLiterals(e, []*Expression{x.Unquote})
+		case x.Discard != nil:
+			err = lispyReadLiterals(nil, []*Expression{x.Discard})
+		}
+
+		//A shorthand needs an expression to work on, so it can't be followed by one that is discarded
+		for _, y := range []*Expression{x.Quote, x.Quasiquote, x.UnquoteSplicing, x.Unquote} {
+			if y != nil && y.Discard != nil {
+				return lexer.Errorf(y.Pos, "#_ cannot come straight after a quote, quasiquote or unquote")
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var lispyParser = participle.MustBuild(&LISPY{},
+	participle.Lexer(lispyLexer),
+	participle.Elide("Whitespace", "Comment", "BlockComment"),
+)
+
+// Parse source text into its top level expressions. Tagged literals are left for lispyRead,
+// since the reader macro for one may be registered by the expressions before it.
+func lispyParse(src string) (*LISPY, error) {
+	root := &LISPY{}
+
+	err := lispyParser.ParseString(src, root)
+	if err == nil {
+		err = lispyReadLiterals(nil, root.Expressions)
+	}
+	if err != nil {
+		return nil, lispyParseError(err)
+	}
+
+	return root, nil
+}
+
+// Read a file off of disk and parse it the same way as lispyParse. Positions in the file are
+// tagged with its path.
+func lispyParseFile(path string) (*LISPY, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root := &LISPY{}
+
+	err = lispyParser.Parse(file, root)
+	if err == nil {
+		err = lispyReadLiterals(nil, root.Expressions)
+	}
+	if err != nil {
+		return nil, lispyParseError(err)
+	}
+
+	return root, nil
+}
+
+// Read a top level expression into an lval, running the reader macros registered in e for the
+// tagged literals in it. This is done just before the expression is evaluated. Gives back nil
+// for an expression commented out with #_.
+func lispyRead(e *LEnv, x *Expression) (*LVal, error) {
+	if x.Discard != nil {
+		return nil, nil
+	}
+
+	if err := lispyReadLiterals(e, []*Expression{x}); err != nil {
+		return nil, lispyParseError(err)
+	}
+
+	return lvalRead(x), nil
+}
+
+// Check whether src opens more brackets than it closes, meaning more input is needed before it
+// can be parsed. Brackets inside strings and comments don't count, and an unclosed string or
+// block comment needs more input too.
+func lispyIncomplete(src string) bool {
+	depth := 0
+	inString := false
+	comments := 0
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		//Block comments nest, so count how deep in them we are
+		if comments > 0 {
+			if strings.HasPrefix(src[i:], "|#") {
+				comments--
+				i++
+			} else if strings.HasPrefix(src[i:], "#|") {
+				comments++
+				i++
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case ';':
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(src)
+			}
+		case '#':
+			//Skip the character in a literal like #\( so it isn't counted as a bracket
+			if strings.HasPrefix(src[i:], "#\\") {
+				i += 2
+			} else if strings.HasPrefix(src[i:], "#|") {
+				comments++
+				i++
+			}
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+	}
+
+	return depth > 0 || inString || comments > 0
+}
+
+// Turn an error from participle into a ParseError, pulling out the position it reports
+func lispyParseError(err error) *ParseError {
+	perr, ok := err.(participle.Error)
+	if !ok {
+		return &ParseError{Message: err.Error()}
+	}
+
+	pos := perr.Position()
+	msg := strings.TrimPrefix(err.Error(), lexer.FormatError(pos, ""))
+
+	return &ParseError{Message: msg, File: pos.Filename, Line: pos.Line, Column: pos.Column}
+}
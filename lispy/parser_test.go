@@ -0,0 +1,160 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{"(list 1 ; a comment (\n 2) ; trailing", "{1 2}"},
+		{"(list 1 #| a #| nested |# ) |# 2 #_(3 4) [5 #_6] 7)", "{1 2 [5] 7}"},
+		{"(list #|||# 1 #| | # |# 2 #|#||#|# 3)", "{1 2 3}"},
+		{"#| spans\n lines |#\n(+ 1 2)", "3"},
+		{"#_(undefined) (list #_1 2 #_ 3 4)", "{2 4}"},
+		{"; only a comment", "()"},
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"(list ' #_1 2)":         "#_ cannot come straight after a quote, quasiquote or unquote",
+		"(list #_)":              `unexpected "#_" (expected ")")`,
+		"(+ 1 2":                 `unexpected "<EOF>" (expected ")")`,
+		"#| never closed":        "",
+		"(list 1)\n(list 1.2.3)": `malformed number "1.2.3"`,
+	}
+
+	for src, want := range tests {
+		_, err := New().Parse(src)
+
+		perr, ok := err.(*ParseError)
+		if !ok || (want != "" && perr.Message != want) {
+			t.Errorf("%q: got %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	_, err := New().EvalString("(def {x} 1)\n  (list 1.2.3)")
+
+	perr, ok := err.(*ParseError)
+	if !ok || perr.Line != 2 || perr.Column != 9 {
+		t.Errorf("got %#v", err)
+	}
+
+	//Nothing runs when the source doesn't parse
+	in := New()
+	if _, err := in.EvalString("(def {x} 1) (+ 1"); err == nil {
+		t.Fatal("unclosed s expression parsed")
+	}
+	if _, err := in.EvalString("x"); err == nil {
+		t.Error("x was defined by source that didn't parse")
+	}
+}
+
+func TestQuoteShorthands(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{"(list 'a '(1 (+ 1 1)) '5 (quote {x}) ''a)", "{a {1 (+ 1 1)} 5 x {quote {a}}}"},
+		{"(def {xs} {1 2 3}) (list `(a ,(+ 1 1) 'b) `(a ,@xs b) `(a ,@{} b) `x)", "{{a 2 (quote {b})} {a 1 2 3 b} {a b} {x}}"},
+		{"(quote 1 2)", "error: Function 'quote' must be given a q expression holding a single expression"},
+		{"`(a ,@1)", "error: unquote-splicing must be given a q expression"},
+	})
+}
+
+func TestReaderMacros(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(reader-macro "up" upper) (list #up"abc" '#up"x" {#up"y"})`, `{"ABC" "X" {"Y"}}`},
+		{`(reader-macro "pair" (fn {s} {list s s})) #pair"a"`, `{"a" "a"}`},
+		{`(reader-macro "bad tag" upper)`, `error: Function 'reader-macro' was given "bad tag", which can't be written as a tag`},
+		{`(reader-macro "x" 1)`, "error: Function 'reader-macro' must be given a tag name and a function"},
+	})
+}
+
+func TestReaderMacroErrors(t *testing.T) {
+	in := New()
+	if _, err := in.EvalString(`(reader-macro "fail" (fn {s} {throw "bad" s}))`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		`#nope"x"`:        "no reader macro for #nope",
+		`(list #fail"x")`: "reader macro #fail failed: x",
+		`#fail"\q"`:       `unknown escape \q in string`,
+	}
+
+	for src, want := range tests {
+		_, err := in.EvalString(src)
+
+		perr, ok := err.(*ParseError)
+		if !ok || perr.Message != want {
+			t.Errorf("%s: got %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestReaderMacrosRunWhenRead(t *testing.T) {
+	for _, treeWalk := range []bool{true, false} {
+		in := New()
+		in.TreeWalk = treeWalk
+
+		if _, err := in.EvalString(`(reader-macro "date" (fn {s} {split s "-"}))`); err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := in.EvalString(`(list #date"2024-01-01" 'x)`)
+		if err != nil || Sprint(v) != `{{"2024" "01" "01"} x}` {
+			t.Errorf("tagged literal gave %v, %v", v, err)
+		}
+
+		if _, err := in.EvalString(`#time"12:00"`); err == nil {
+			t.Errorf("tagged literal without a reader macro parsed")
+		}
+
+		//Each expression is read just before it runs, so one can use a reader macro the one before it registered
+		v, err = in.EvalString(`(reader-macro "up" upper) #up"abc"`)
+		if err != nil || Sprint(v) != `"ABC"` {
+			t.Errorf("tagged literal after registering its reader macro gave %v, %v", v, err)
+		}
+
+		//but not the expression that registers it, which has already been read
+		if _, err := in.EvalString(`(list (reader-macro "low" lower) #low"ABC")`); err == nil {
+			t.Errorf("tagged literal in the expression registering its reader macro was read")
+		}
+
+		//Discarded expressions never run their reader macros
+		if _, err := in.EvalString(`#_#nothing"x" 1`); err != nil {
+			t.Errorf("discarded tagged literal gave %v", err)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := map[string]bool{
+		"":                   false,
+		"(+ 1 2)":            false,
+		"(+ 1":               true,
+		"(def {f} [1 #{":     true,
+		"(+ 1 2))":           false,
+		`(list "(")`:         false,
+		`(list ")"`:          true,
+		`"abc`:               true,
+		`"a\"(" "\\"`:        false,
+		`(list #\()`:         false,
+		`(list #\)`:          true,
+		`#\{ #\[`:            false,
+		"#| ( |#":            false,
+		"#| #| |# ( |#":      false,
+		"#| #| |# (":         true,
+		"#| #| |# |# (":      true,
+		"(list #_(1 2)":      true,
+		"; (\n1":             false,
+		"(list ; )\n1":       true,
+		"(list 1) ; comment": false,
+	}
+
+	for src, want := range tests {
+		if got := Incomplete(src); got != want {
+			t.Errorf("%q: got %v, want %v", src, got, want)
+		}
+	}
+}
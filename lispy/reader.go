@@ -0,0 +1,69 @@
+package lispy
+
+import (
+	"fmt"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains reader macros, which let lispy code add new literal syntax. A tagged  //
+// literal like #date"2024-01-01" is read by calling the function registered for its tag    //
+// with the text of the string, and whatever it gives back is read in place of the literal. //
+// Each top level expression is read just before it is evaluated, so a reader macro can be  //
+// used by any expression after the one that registers it, even in the same file.           //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Read a tagged literal by calling the reader macro for its tag on its text
+func lvalReadTagged(e *LEnv, tag string, text string) (*LVal, error) {
+	name := strings.TrimPrefix(tag, "#")
+
+	root := lenvRoot(e)
+	f, ok := root.readers[name]
+	if !ok {
+		return nil, fmt.Errorf("no reader macro for #%s", name)
+	}
+
+	args := lvalSexpr()
+	lvalAdd(args, lvalString(text))
+
+	x := lvalForce(lvalCall(root, f, args))
+	if x.Type == LVAL_ERR {
+		return nil, fmt.Errorf("reader macro #%s failed: %s", name, x.Err)
+	}
+
+	return x, nil
+}
+
+// Check that name can be written as a tag, starting with a letter and made up of letters,
+// digits, -, _ and /
+func lvalIsTag(name string) bool {
+	for i, c := range name {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+
+		if !letter && (i == 0 || !strings.ContainsRune("0123456789-_/", c)) {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+// (reader-macro "tag" f) makes #tag"text" read as whatever (f "text") gives back
+func builtinReaderMacro(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 || a.Cell[0].Type != LVAL_STR || a.Cell[1].Type != LVAL_FUN {
+		return lvalErr("Function 'reader-macro' must be given a tag name and a function")
+	}
+
+	name := strings.TrimPrefix(a.Cell[0].String, "#")
+	if !lvalIsTag(name) {
+		return lvalErr("Function 'reader-macro' was given \"" + name + "\", which can't be written as a tag")
+	}
+
+	root := lenvRoot(e)
+	if root.readers == nil {
+		root.readers = make(map[string]*LVal)
+	}
+	root.readers[name] = a.Cell[1]
+
+	return lvalSexpr()
+}
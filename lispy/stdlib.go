@@ -0,0 +1,234 @@
+package lispy
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the standard library, which is a curated set of functions from the    //
+// golang standard library. Each go package becomes a module whose functions are named like //
+// strings/split or math/sqrt, and they all go through the interop in interop.go.           //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Add a go function that is known to convert cleanly. Failing here is a bug in the stdlib.
+func lenvMustAddGoFunc(e *LEnv, name string, fn interface{}) {
+	if err := lenvAddGoFunc(e, name, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Initialize the global environment with every standard library module
+func lenvAddStdlib(e *LEnv) {
+	lenvAddStrings(e)
+	lenvAddStrconv(e)
+	lenvAddMath(e)
+	lenvAddCmath(e)
+	lenvAddOs(e)
+	lenvAddTime(e)
+	lenvAddFilepath(e)
+	lenvAddSort(e)
+}
+
+// strings/contains s sub, strings/has-prefix s p, strings/has-suffix s p, strings/index s sub,
+// strings/count s sub, strings/split s sep, strings/fields s, strings/join {strs} sep,
+// strings/repeat s n, strings/replace s old new, strings/upper s, strings/lower s,
+// strings/trim s, strings/trim-prefix s p, strings/trim-suffix s p
+func lenvAddStrings(e *LEnv) {
+	lenvMustAddGoFunc(e, "strings/contains", strings.Contains)
+	lenvMustAddGoFunc(e, "strings/has-prefix", strings.HasPrefix)
+	lenvMustAddGoFunc(e, "strings/has-suffix", strings.HasSuffix)
+	lenvMustAddGoFunc(e, "strings/index", strings.Index)
+	lenvMustAddGoFunc(e, "strings/count", strings.Count)
+	lenvMustAddGoFunc(e, "strings/split", strings.Split)
+	lenvMustAddGoFunc(e, "strings/fields", strings.Fields)
+	lenvMustAddGoFunc(e, "strings/join", strings.Join)
+	lenvMustAddGoFunc(e, "strings/repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("Function 'strings/repeat' must be given a count that isn't negative")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	lenvMustAddGoFunc(e, "strings/replace", strings.ReplaceAll)
+	lenvMustAddGoFunc(e, "strings/upper", strings.ToUpper)
+	lenvMustAddGoFunc(e, "strings/lower", strings.ToLower)
+	lenvMustAddGoFunc(e, "strings/trim", strings.TrimSpace)
+	lenvMustAddGoFunc(e, "strings/trim-prefix", strings.TrimPrefix)
+	lenvMustAddGoFunc(e, "strings/trim-suffix", strings.TrimSuffix)
+}
+
+// strconv/itoa n, strconv/atoi s, strconv/parse-float s, strconv/format-float x digits,
+// strconv/quote s
+func lenvAddStrconv(e *LEnv) {
+	lenvMustAddGoFunc(e, "strconv/itoa", strconv.Itoa)
+	lenvMustAddGoFunc(e, "strconv/atoi", strconv.Atoi)
+	lenvMustAddGoFunc(e, "strconv/parse-float", func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+	lenvMustAddGoFunc(e, "strconv/format-float", func(x float64, digits int) string {
+		return strconv.FormatFloat(x, 'f', digits, 64)
+	})
+	lenvMustAddGoFunc(e, "strconv/quote", strconv.Quote)
+}
+
+// math/pi, math/e, math/inf and math/nan are numbers. Everything else takes numbers:
+// math/sqrt, math/pow, math/abs, math/floor, math/ceil, math/round, math/trunc, math/mod,
+// math/exp, math/log, math/log2, math/log10, math/sin, math/cos, math/tan, math/asin,
+// math/acos, math/atan, math/atan2, math/hypot, math/max, math/min, math/is-nan, math/is-inf
+func lenvAddMath(e *LEnv) {
+	lenvPut(e, lvalSym("math/pi"), lvalFloat(math.Pi))
+	lenvPut(e, lvalSym("math/e"), lvalFloat(math.E))
+	lenvPut(e, lvalSym("math/inf"), lvalFloat(math.Inf(1)))
+	lenvPut(e, lvalSym("math/nan"), lvalFloat(math.NaN()))
+
+	lenvMustAddGoFunc(e, "math/sqrt", math.Sqrt)
+	lenvMustAddGoFunc(e, "math/pow", math.Pow)
+	lenvMustAddGoFunc(e, "math/abs", math.Abs)
+	lenvMustAddGoFunc(e, "math/floor", math.Floor)
+	lenvMustAddGoFunc(e, "math/ceil", math.Ceil)
+	lenvMustAddGoFunc(e, "math/round", math.Round)
+	lenvMustAddGoFunc(e, "math/trunc", math.Trunc)
+	lenvMustAddGoFunc(e, "math/mod", math.Mod)
+	lenvMustAddGoFunc(e, "math/exp", math.Exp)
+	lenvMustAddGoFunc(e, "math/log", math.Log)
+	lenvMustAddGoFunc(e, "math/log2", math.Log2)
+	lenvMustAddGoFunc(e, "math/log10", math.Log10)
+	lenvMustAddGoFunc(e, "math/sin", math.Sin)
+	lenvMustAddGoFunc(e, "math/cos", math.Cos)
+	lenvMustAddGoFunc(e, "math/tan", math.Tan)
+	lenvMustAddGoFunc(e, "math/asin", math.Asin)
+	lenvMustAddGoFunc(e, "math/acos", math.Acos)
+	lenvMustAddGoFunc(e, "math/atan", math.Atan)
+	lenvMustAddGoFunc(e, "math/atan2", math.Atan2)
+	lenvMustAddGoFunc(e, "math/hypot", math.Hypot)
+	lenvMustAddGoFunc(e, "math/max", math.Max)
+	lenvMustAddGoFunc(e, "math/min", math.Min)
+	lenvMustAddGoFunc(e, "math/is-nan", math.IsNaN)
+	lenvMustAddGoFunc(e, "math/is-inf", func(x float64) bool {
+		return math.IsInf(x, 0)
+	})
+}
+
+// The cmath module works on complex numbers, and takes any other number as one with no
+// imaginary part: cmath/abs, cmath/phase, cmath/exp, cmath/sqrt, cmath/log, cmath/conj,
+// cmath/real, cmath/imag
+func lenvAddCmath(e *LEnv) {
+	lenvMustAddGoFunc(e, "cmath/abs", cmplx.Abs)
+	lenvMustAddGoFunc(e, "cmath/phase", cmplx.Phase)
+	lenvMustAddGoFunc(e, "cmath/exp", cmplx.Exp)
+	lenvMustAddGoFunc(e, "cmath/sqrt", cmplx.Sqrt)
+	lenvMustAddGoFunc(e, "cmath/log", cmplx.Log)
+	lenvMustAddGoFunc(e, "cmath/conj", cmplx.Conj)
+	lenvMustAddGoFunc(e, "cmath/real", func(x complex128) float64 {
+		return real(x)
+	})
+	lenvMustAddGoFunc(e, "cmath/imag", func(x complex128) float64 {
+		return imag(x)
+	})
+}
+
+// os/args, os/getenv name, os/setenv name value, os/lookup-env name (gives {value found}),
+// os/getwd, os/hostname. os/exit code is only there once the embedder calls EnableExit.
+func lenvAddOs(e *LEnv) {
+	lenvMustAddGoFunc(e, "os/args", func() []string {
+		return os.Args
+	})
+	lenvMustAddGoFunc(e, "os/getenv", os.Getenv)
+	lenvMustAddGoFunc(e, "os/setenv", os.Setenv)
+	lenvMustAddGoFunc(e, "os/lookup-env", os.LookupEnv)
+	lenvMustAddGoFunc(e, "os/getwd", os.Getwd)
+	lenvMustAddGoFunc(e, "os/hostname", os.Hostname)
+}
+
+// os/exit raises an error of type "exit" holding the code rather than ending the program there
+// and then, so finally clauses still run and the embedder gets to clean up before it exits. No
+// catch clause catches it.
+func builtinOsExit(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'os/exit' must be given an exit code")
+	}
+
+	n, ok := lvalToInt64(a.Cell[0])
+	if !ok || n < 0 || n > 255 {
+		return lvalErr("Function 'os/exit' must be given a whole number from 0 to 255")
+	}
+
+	x := lvalErr("exit")
+	x.ErrType = "exit"
+	x.ErrValue = lvalInt(n)
+	x.exit = true
+
+	return x
+}
+
+// Times are passed around as seconds since the unix epoch.
+// time/now, time/since t, time/sleep seconds, time/format t layout, time/parse layout s.
+// Layouts use go's reference time, e.g. "2006-01-02".
+func lenvAddTime(e *LEnv) {
+	lenvMustAddGoFunc(e, "time/now", func() float64 {
+		return timeToSeconds(time.Now())
+	})
+	lenvMustAddGoFunc(e, "time/since", func(t float64) float64 {
+		return timeToSeconds(time.Now()) - t
+	})
+	lenvMustAddGoFunc(e, "time/sleep", func(seconds float64) {
+		time.Sleep(time.Duration(seconds * float64(time.Second)))
+	})
+	lenvMustAddGoFunc(e, "time/format", func(t float64, layout string) string {
+		return secondsToTime(t).Format(layout)
+	})
+	lenvMustAddGoFunc(e, "time/parse", func(layout string, s string) (float64, error) {
+		t, err := time.Parse(layout, s)
+		return timeToSeconds(t), err
+	})
+}
+
+func timeToSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func secondsToTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+}
+
+// filepath/join & parts, filepath/base p, filepath/dir p, filepath/ext p, filepath/clean p,
+// filepath/abs p, filepath/rel base target, filepath/is-abs p, filepath/glob pattern
+func lenvAddFilepath(e *LEnv) {
+	lenvMustAddGoFunc(e, "filepath/join", filepath.Join)
+	lenvMustAddGoFunc(e, "filepath/base", filepath.Base)
+	lenvMustAddGoFunc(e, "filepath/dir", filepath.Dir)
+	lenvMustAddGoFunc(e, "filepath/ext", filepath.Ext)
+	lenvMustAddGoFunc(e, "filepath/clean", filepath.Clean)
+	lenvMustAddGoFunc(e, "filepath/abs", filepath.Abs)
+	lenvMustAddGoFunc(e, "filepath/rel", filepath.Rel)
+	lenvMustAddGoFunc(e, "filepath/is-abs", filepath.IsAbs)
+	lenvMustAddGoFunc(e, "filepath/glob", filepath.Glob)
+}
+
+// sort/numbers {nums} and sort/strings {strs} both give back a sorted list
+func lenvAddSort(e *LEnv) {
+	lenvMustAddGoFunc(e, "sort/numbers", func(xs []*LVal) ([]*LVal, error) {
+		for i := 0; i < len(xs); i++ {
+			if !lvalIsReal(xs[i]) {
+				return nil, errors.New("Function 'sort/numbers' must be given a q expression of real numbers")
+			}
+		}
+
+		//Sorting the lvals themselves keeps integers and rationals exact
+		sort.SliceStable(xs, func(i, j int) bool {
+			return lvalNumCompare("<", xs[i], xs[j])
+		})
+		return xs, nil
+	})
+	lenvMustAddGoFunc(e, "sort/strings", func(xs []string) []string {
+		sort.Strings(xs)
+		return xs
+	})
+}
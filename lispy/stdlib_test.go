@@ -0,0 +1,73 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestStdlib(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list (strings/contains "abc" "b") (strings/has-prefix "abc" "ab") (strings/has-suffix "abc" "bc") (strings/index "abc" "c") (strings/count "banana" "a"))`, "{true true true 2 3}"},
+		{`(list (strings/split "a,b" ",") (strings/fields " a  b ") (strings/join {"a" "b"} "-") (strings/replace "aXa" "a" "b"))`, `{{"a" "b"} {"a" "b"} "a-b" "bXb"}`},
+		{`(list (strings/repeat "ab" 3) (strings/repeat "ab" 0) (strings/upper "é") (strings/trim " x ") (strings/trim-prefix "abc" "a") (strings/trim-suffix "abc" "c"))`, `{"ababab" "" "É" "x" "bc" "ab"}`},
+		{`(strings/repeat "a" -1)`, "error: Function 'strings/repeat' must be given a count that isn't negative"},
+		{`(strings/split "a" 1)`, "error: Function 'strings/split' must be given a string as argument 2"},
+		{`(list (strconv/itoa 42) (strconv/atoi "42") (strconv/parse-float "1.5") (strconv/format-float 1.25 1) (strconv/quote "a\"b"))`, `{"42" 42 1.5 "1.2" "\"a\\\"b\""}`},
+		{`(strconv/atoi "x")`, `error: strconv.Atoi: parsing "x": invalid syntax`},
+		{`(list (math/sqrt 16) (math/pow 2 10) (math/abs -3) (math/floor 1.5) (math/ceil 1.5) (math/round 2.5) (math/trunc -1.5) (math/mod 7 3))`, "{4.0 1024.0 3.0 1.0 2.0 3.0 -1.0 1.0}"},
+		{`(list (math/exp 0) (math/log 1) (math/log2 8) (math/log10 1000) (math/sin 0) (math/cos 0) (math/atan2 0 1) (math/hypot 3 4))`, "{1.0 0.0 3.0 3.0 0.0 1.0 0.0 5.0}"},
+		{`(list (math/max 1 2) (math/min 1 2) (math/is-nan math/nan) (math/is-inf math/inf) (> math/pi 3.14) (< math/e 2.72))`, "{2.0 1.0 true true true true}"},
+		{`(math/sqrt 1i)`, "error: Function 'math/sqrt' must be given a real number as argument 1"},
+		{`(list (cmath/abs 3+4i) (cmath/sqrt -4) (cmath/conj 1+2i) (cmath/real 1+2i) (cmath/imag 1+2i) (cmath/exp 0) (cmath/phase 1))`, "{5.0 0+2i 1-2i 1.0 2.0 1+0i 0.0}"},
+		{`(list (os/setenv "LISPY_TEST" "x") (os/getenv "LISPY_TEST") (os/lookup-env "LISPY_TEST") (os/lookup-env "LISPY_UNSET_VAR"))`, `{() "x" {"x" true} {"" false}}`},
+		{`(os/exit 1)`, "error: Unbound Symbol"},
+		{`(list (time/format 0 "2006-01-02") (time/parse "2006-01-02" "1970-01-02") (> (apply time/now {}) 0) (>= (time/since (apply time/now {})) 0))`, `{"1970-01-01" 86400.0 true true}`},
+		{`(time/parse "2006" "x")`, `error: parsing time "x" as "2006": cannot parse "x" as "2006"`},
+		{`(list (filepath/join "a" "b" "c") (filepath/base "a/b.go") (filepath/dir "a/b.go") (filepath/ext "a/b.go") (filepath/clean "a/../b") (filepath/rel "a" "a/b") (filepath/is-abs "/a"))`, `{"a/b/c" "b.go" "a" ".go" "b" "b" true}`},
+		{`(list (sort/numbers (list 3 1.5 (/ 1 2) 2)) (sort/strings {"b" "a"}))`, `{{1/2 1.5 2 3} {"a" "b"}}`},
+		{`(sort/numbers {1 "a"})`, "error: Function 'sort/numbers' must be given a q expression of real numbers"},
+	})
+}
+
+func TestExitIsOptIn(t *testing.T) {
+	for _, treeWalk := range []bool{true, false} {
+		in := New()
+		in.TreeWalk = treeWalk
+		in.EnableExit()
+
+		_, err := in.EvalString(`(def {cleaned} false) (try {os/exit 3} {finally {def {cleaned} true}})`)
+		lerr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("os/exit gave %v", err)
+		}
+
+		if code, ok := lerr.ExitCode(); !ok || code != 3 {
+			t.Errorf("os/exit gave code %d, %v", code, ok)
+		}
+
+		if v, _ := in.EvalString(`cleaned`); Sprint(v) != "true" {
+			t.Errorf("finally didn't run before exiting")
+		}
+
+		//Catch clauses let it through, after running the finally clause
+		_, err = in.EvalString(`(def {cleaned} false) (try {os/exit 4} {catch {e} {0}} {finally {def {cleaned} true}}) 1`)
+		if code, ok := err.(*Error).ExitCode(); !ok || code != 4 {
+			t.Errorf("catch stopped os/exit, giving %v", err)
+		}
+		if v, _ := in.EvalString(`cleaned`); Sprint(v) != "true" {
+			t.Errorf("finally didn't run before exiting")
+		}
+
+		//Only os/exit can make an error that exits
+		if _, err := in.EvalString(`(throw "exit" "bye" 7)`); err == nil {
+			t.Errorf("throw gave no error")
+		} else if _, ok := err.(*Error).ExitCode(); ok {
+			t.Errorf("a thrown error gave an exit code")
+		}
+
+		if _, err := in.EvalString(`(os/exit 256)`); err == nil {
+			t.Errorf("os/exit accepted 256")
+		} else if _, ok := err.(*Error).ExitCode(); ok {
+			t.Errorf("os/exit 256 gave an exit code")
+		}
+	}
+}
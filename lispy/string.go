@@ -0,0 +1,224 @@
+package lispy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains strings. Literals can hold any UTF-8 text along with the escapes \n,  //
+// \t, \r, \", \\ and \u{...}, and strings are printed with those escapes put back in. The  //
+// string builtins count in runes rather than bytes, so indexes line up with characters    //
+// whatever language the text is in.                                                       //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// Take the quotes off of a string literal and turn its escapes into the characters they stand for
+func lvalUnescapeStr(lit string) (string, error) {
+	lit = lit[1 : len(lit)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' {
+			b.WriteByte(lit[i])
+			continue
+		}
+
+		i++
+		switch lit[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"':
+			b.WriteByte('"')
+		case '\\':
+			b.WriteByte('\\')
+		case 'u':
+			end := strings.IndexByte(lit[i:], '}')
+			if !strings.HasPrefix(lit[i:], "u{") || end < 0 {
+				return "", fmt.Errorf("\\u must be followed by a code point in braces, like \\u{e9}")
+			}
+
+			r, err := strconv.ParseUint(lit[i+2:i+end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("invalid code point \\%s in string", lit[i:i+end+1])
+			}
+
+			b.WriteRune(rune(r))
+			i += end
+		default:
+			r, _ := utf8.DecodeRuneInString(lit[i:])
+			return "", fmt.Errorf("unknown escape \\%c in string", r)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Put escapes back into a string so that it reads back in as the same string
+func lvalEscapeStr(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		switch {
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '"':
+			b.WriteString("\\\"")
+		case r == '\\':
+			b.WriteString("\\\\")
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, "\\u{%x}", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Check that the arguments are all strings, giving back an error for the builtin name if not
+func lvalCheckStrs(name string, args []*LVal, n int) *LVal {
+	if len(args) != n {
+		return lvalErr(fmt.Sprintf("Function '%s' must be given %d arguments", name, n))
+	}
+
+	for i := 0; i < len(args); i++ {
+		if args[i].Type != LVAL_STR {
+			return lvalErr(fmt.Sprintf("Function '%s' must be given a string as argument %d", name, i+1))
+		}
+	}
+
+	return nil
+}
+
+// (str-len s) gives back the number of characters in s
+func builtinStrLen(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("str-len", a.Cell, 1); err != nil {
+		return err
+	}
+
+	return lvalInt(int64(utf8.RuneCountInString(a.Cell[0].String)))
+}
+
+// (substr s start end) gives back the characters of s from start up to but not including end
+func builtinSubstr(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 3 || a.Cell[0].Type != LVAL_STR {
+		return lvalErr("Function 'substr' must be given a string, a start and an end")
+	}
+
+	runes := []rune(a.Cell[0].String)
+
+	start, err := lvalIndex("substr", a.Cell[1], len(runes), true)
+	if err != nil {
+		return err
+	}
+
+	end, err := lvalIndex("substr", a.Cell[2], len(runes), true)
+	if err != nil {
+		return err
+	}
+
+	if end < start {
+		return lvalErr("Function 'substr' must be given an end that isn't before its start")
+	}
+
+	return lvalString(string(runes[start:end]))
+}
+
+// (str-index s sub) gives back the index of the character sub first starts at in s, or nil if
+// sub isn't in s
+func builtinStrIndex(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("str-index", a.Cell, 2); err != nil {
+		return err
+	}
+
+	i := strings.Index(a.Cell[0].String, a.Cell[1].String)
+	if i < 0 {
+		return lvalNil()
+	}
+
+	return lvalInt(int64(utf8.RuneCountInString(a.Cell[0].String[:i])))
+}
+
+// (upper s) and (lower s) change the case of every letter in s
+func builtinUpper(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("upper", a.Cell, 1); err != nil {
+		return err
+	}
+
+	return lvalString(strings.ToUpper(a.Cell[0].String))
+}
+
+func builtinLower(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("lower", a.Cell, 1); err != nil {
+		return err
+	}
+
+	return lvalString(strings.ToLower(a.Cell[0].String))
+}
+
+// (split s sep) gives back a q expression of the parts of s between each sep. An empty sep
+// splits s into its characters.
+func builtinSplit(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("split", a.Cell, 2); err != nil {
+		return err
+	}
+
+	parts := strings.Split(a.Cell[0].String, a.Cell[1].String)
+
+	x := lvalQexpr()
+	for i := 0; i < len(parts); i++ {
+		lvalAdd(x, lvalString(parts[i]))
+	}
+
+	return x
+}
+
+// (join-str strs sep) joins a q expression or vector of strings together with sep between each
+func builtinJoinStr(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 || (a.Cell[0].Type != LVAL_QEXPR && a.Cell[0].Type != LVAL_VECTOR) || a.Cell[1].Type != LVAL_STR {
+		return lvalErr("Function 'join-str' must be given a q expression or vector of strings and a separator")
+	}
+
+	strs := a.Cell[0].Cell
+	if a.Cell[0].Type == LVAL_VECTOR {
+		strs = a.Cell[0].Vec.items()
+	}
+
+	parts := make([]string, len(strs))
+	for i := 0; i < len(strs); i++ {
+		if strs[i].Type != LVAL_STR {
+			return lvalErr("Function 'join-str' can only join strings")
+		}
+		parts[i] = strs[i].String
+	}
+
+	return lvalString(strings.Join(parts, a.Cell[1].String))
+}
+
+// (trim s) takes the whitespace off both ends of s
+func builtinTrim(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("trim", a.Cell, 1); err != nil {
+		return err
+	}
+
+	return lvalString(strings.TrimSpace(a.Cell[0].String))
+}
+
+// (replace s old new) replaces every old in s with new
+func builtinReplace(e *LEnv, a *LVal) *LVal {
+	if err := lvalCheckStrs("replace", a.Cell, 3); err != nil {
+		return err
+	}
+
+	return lvalString(strings.Replace(a.Cell[0].String, a.Cell[1].String, a.Cell[2].String, -1))
+}
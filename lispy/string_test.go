@@ -0,0 +1,36 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestStrings(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(list "tab\there\n \"q\" \\ \u{e9}" "\u{1F600}" "😀")`, `{"tab\there\n \"q\" \\ é" "😀" "😀"}`},
+		{`(list (str-len "héllo") (str-len "\u{1F600}") (str-len "") (substr "héllo wörld" 1 8) (str-index "héllo wörld" "wö") (str-index "abc" "z"))`, `{5 1 0 "éllo wö" 6 nil}`},
+		{`(list (upper "straße") (lower "ÉCOLE") (trim "  hi \n") (replace "aXbXc" "X" "--"))`, `{"STRAßE" "école" "hi" "a--b--c"}`},
+		{`(list (split "a,b,,c" ",") (split "" ",") (join-str ["x" "y"] ", ") (join-str {} ","))`, `{{"a" "b" "" "c"} {""} "x, y" ""}`},
+		{`(list (== "a" "a") (== "a" "b") (== "é" "\u{e9}"))`, "{true false true}"},
+		{`(substr "abc" 2 1)`, "error: Function 'substr' must be given an end that isn't before its start"},
+		{`(substr "abc" 0 4)`, "error: Function 'substr' was given an index out of range"},
+		{`(str-len 1)`, "error: Function 'str-len' must be given a string as argument 1"},
+		{`(join-str {1} ",")`, "error: Function 'join-str' can only join strings"},
+	})
+}
+
+func TestStringLiteralErrors(t *testing.T) {
+	tests := map[string]string{
+		`"\q"`:          `unknown escape \q in string`,
+		`"\u{110000}"`:  `invalid code point \u{110000} in string`,
+		`(list 1 "\z")`: `unknown escape \z in string`,
+	}
+
+	for src, want := range tests {
+		_, err := New().Parse(src)
+
+		perr, ok := err.(*ParseError)
+		if !ok || perr.Message != want {
+			t.Errorf("%s: got %v, want %s", src, err, want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package lispy
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains vectors. A vector is written [a b c], which reads as a call to vector //
+// so the elements are evaluated. They are persistent vectors like clojure's: a tree with   //
+// 32 elements or children in each node, plus a tail holding the last few elements. nth is //
+// a walk down a tree that is never more than a few levels deep, and push and assoc only   //
+// copy the nodes on the way down to the element they change, sharing everything else with //
+// the vector they were given.                                                              //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+const (
+	lvecBits  = 5
+	lvecWidth = 1 << lvecBits
+	lvecMask  = lvecWidth - 1
+)
+
+type lvector struct {
+	count int
+	shift uint
+	root  *lvecNode
+	tail  []*LVal
+}
+
+// Branches have kids and leaves have items, which are always full
+type lvecNode struct {
+	kids  []*lvecNode
+	items []*LVal
+}
+
+var lvecEmpty = &lvector{shift: lvecBits, root: &lvecNode{}}
+
+func lvalVector(v *lvector) *LVal {
+	val := LVal{Type: LVAL_VECTOR, Vec: v}
+	return &val
+}
+
+// Build a vector out of the given elements
+func lvecFrom(items []*LVal) *lvector {
+	v := lvecEmpty
+	for i := 0; i < len(items); i++ {
+		v = v.push(items[i])
+	}
+
+	return v
+}
+
+// The elements of the vector in order
+func (v *lvector) items() []*LVal {
+	items := make([]*LVal, v.count)
+	for i := 0; i < v.count; i++ {
+		items[i] = v.nth(i)
+	}
+
+	return items
+}
+
+// Index of the first element held in the tail
+func (v *lvector) tailOffset() int {
+	if v.count < lvecWidth {
+		return 0
+	}
+
+	return ((v.count - 1) >> lvecBits) << lvecBits
+}
+
+func (v *lvector) nth(i int) *LVal {
+	if i >= v.tailOffset() {
+		return v.tail[i-v.tailOffset()]
+	}
+
+	node := v.root
+	for level := v.shift; level > 0; level -= lvecBits {
+		node = node.kids[(i>>level)&lvecMask]
+	}
+
+	return node.items[i&lvecMask]
+}
+
+func (v *lvector) push(x *LVal) *lvector {
+	//There is room left in the tail
+	if v.count-v.tailOffset() < lvecWidth {
+		tail := append(v.tail[:len(v.tail):len(v.tail)], x)
+		return &lvector{count: v.count + 1, shift: v.shift, root: v.root, tail: tail}
+	}
+
+	//The tail is full, so it goes into the tree and a new one is started
+	leaf := &lvecNode{items: v.tail}
+	root, shift := v.root, v.shift
+
+	if v.count>>lvecBits > 1<<v.shift {
+		//The tree is full too, so it gets a new root one level higher
+		root = &lvecNode{kids: []*lvecNode{v.root, lvecPath(v.shift, leaf)}}
+		shift += lvecBits
+	} else {
+		root = v.pushLeaf(v.shift, v.root, leaf)
+	}
+
+	return &lvector{count: v.count + 1, shift: shift, root: root, tail: []*LVal{x}}
+}
+
+// Copy the nodes from parent down to where leaf goes, adding it on the end
+func (v *lvector) pushLeaf(level uint, parent *lvecNode, leaf *lvecNode) *lvecNode {
+	i := ((v.count - 1) >> level) & lvecMask
+	node := &lvecNode{kids: append([]*lvecNode{}, parent.kids...)}
+
+	if level == lvecBits {
+		node.kids = append(node.kids, leaf)
+	} else if i < len(parent.kids) {
+		node.kids[i] = v.pushLeaf(level-lvecBits, parent.kids[i], leaf)
+	} else {
+		node.kids = append(node.kids, lvecPath(level-lvecBits, leaf))
+	}
+
+	return node
+}
+
+// Wrap leaf in single child branches until it reaches level
+func lvecPath(level uint, leaf *lvecNode) *lvecNode {
+	if level == 0 {
+		return leaf
+	}
+
+	return &lvecNode{kids: []*lvecNode{lvecPath(level-lvecBits, leaf)}}
+}
+
+// Give back a vector with element i set to x. i may be the count, which pushes x.
+func (v *lvector) assoc(i int, x *LVal) *lvector {
+	if i == v.count {
+		return v.push(x)
+	}
+
+	if i >= v.tailOffset() {
+		tail := append([]*LVal{}, v.tail...)
+		tail[i-v.tailOffset()] = x
+		return &lvector{count: v.count, shift: v.shift, root: v.root, tail: tail}
+	}
+
+	return &lvector{count: v.count, shift: v.shift, root: lvecAssoc(v.shift, v.root, i, x), tail: v.tail}
+}
+
+func lvecAssoc(level uint, node *lvecNode, i int, x *LVal) *lvecNode {
+	if level == 0 {
+		items := append([]*LVal{}, node.items...)
+		items[i&lvecMask] = x
+		return &lvecNode{items: items}
+	}
+
+	kids := append([]*lvecNode{}, node.kids...)
+	kids[(i>>level)&lvecMask] = lvecAssoc(level-lvecBits, kids[(i>>level)&lvecMask], i, x)
+	return &lvecNode{kids: kids}
+}
+
+// Check that two vectors hold equal elements
+func lvalVectorEq(x *LVal, y *LVal) bool {
+	if x.Vec.count != y.Vec.count {
+		return false
+	}
+
+	for i := 0; i < x.Vec.count; i++ {
+		if !lvalEq(x.Vec.nth(i), y.Vec.nth(i)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Read an index for a list or vector of length n. An index of n is allowed when end is set.
+func lvalIndex(name string, x *LVal, n int, end bool) (int, *LVal) {
+	if x.Type != LVAL_INT {
+		return 0, lvalErr("Function '" + name + "' must be given a whole number as an index")
+	}
+
+	if x.Int < 0 || x.Int > int64(n) || (x.Int == int64(n) && !end) {
+		return 0, lvalErr("Function '" + name + "' was given an index out of range")
+	}
+
+	return int(x.Int), nil
+}
+
+// (vector a b ...) makes a vector of its arguments. [a b ...] reads as a call to it.
+func builtinVector(e *LEnv, a *LVal) *LVal {
+	return lvalVector(lvecFrom(a.Cell))
+}
+
+// (nth v i) gives back element i of a vector or q expression, counting from 0
+func builtinNth(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 2 || (a.Cell[0].Type != LVAL_VECTOR && a.Cell[0].Type != LVAL_QEXPR) {
+		return lvalErr("Function 'nth' must be given a vector or q expression and an index")
+	}
+
+	if a.Cell[0].Type == LVAL_QEXPR {
+		i, err := lvalIndex("nth", a.Cell[1], len(a.Cell[0].Cell), false)
+		if err != nil {
+			return err
+		}
+		return a.Cell[0].Cell[i]
+	}
+
+	i, err := lvalIndex("nth", a.Cell[1], a.Cell[0].Vec.count, false)
+	if err != nil {
+		return err
+	}
+	return a.Cell[0].Vec.nth(i)
+}
+
+// (count x) gives back the number of elements in a vector or q expression, or the number of
+// keys in a map
+func builtinCount(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 {
+		return lvalErr("Function 'count' must be given a single argument")
+	}
+
+	switch a.Cell[0].Type {
+	case LVAL_VECTOR:
+		return lvalInt(int64(a.Cell[0].Vec.count))
+	case LVAL_QEXPR:
+		return lvalInt(int64(len(a.Cell[0].Cell)))
+	case LVAL_MAP:
+		return lvalInt(int64(len(a.Cell[0].Map)))
+	}
+
+	return lvalErr("Function 'count' must be given a vector, q expression or map")
+}
+
+// (slice v start end) gives back the elements of a vector or q expression from start up to
+// but not including end
+func builtinSlice(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 3 || (a.Cell[0].Type != LVAL_VECTOR && a.Cell[0].Type != LVAL_QEXPR) {
+		return lvalErr("Function 'slice' must be given a vector or q expression, a start and an end")
+	}
+
+	n := len(a.Cell[0].Cell)
+	if a.Cell[0].Type == LVAL_VECTOR {
+		n = a.Cell[0].Vec.count
+	}
+
+	start, err := lvalIndex("slice", a.Cell[1], n, true)
+	if err != nil {
+		return err
+	}
+
+	end, err := lvalIndex("slice", a.Cell[2], n, true)
+	if err != nil {
+		return err
+	}
+
+	if end < start {
+		return lvalErr("Function 'slice' must be given an end that isn't before its start")
+	}
+
+	if a.Cell[0].Type == LVAL_QEXPR {
+		//The slice shares its cells with the list it came from
+		v := lvalAs(a.Cell[0], LVAL_QEXPR)
+		v.Cell = v.Cell[start:end:end]
+		return v
+	}
+
+	v := lvecEmpty
+	for i := start; i < end; i++ {
+		v = v.push(a.Cell[0].Vec.nth(i))
+	}
+
+	return lvalVector(v)
+}
+
+// (push v x ...) gives back v with each x added on the end
+func builtinPush(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) == 0 || a.Cell[0].Type != LVAL_VECTOR {
+		return lvalErr("Function 'push' must be given a vector as its first argument")
+	}
+
+	v := a.Cell[0].Vec
+	for i := 1; i < len(a.Cell); i++ {
+		v = v.push(a.Cell[i])
+	}
+
+	return lvalVector(v)
+}
+
+// (assoc v i x ...) gives back v with each element i set to x, where an i of (count v) adds x
+// on the end. Maps are handled by the assoc for maps.
+func lvalVectorAssoc(a *LVal) *LVal {
+	if len(a.Cell)%2 != 1 {
+		return lvalErr("Function 'assoc' must be given indexes and values in pairs")
+	}
+
+	v := a.Cell[0].Vec
+	for i := 1; i < len(a.Cell); i += 2 {
+		j, err := lvalIndex("assoc", a.Cell[i], v.count, true)
+		if err != nil {
+			return err
+		}
+		v = v.assoc(j, a.Cell[i+1])
+	}
+
+	return lvalVector(v)
+}
+
+// (list->vector {a b ...}) makes a vector holding the elements of a q expression
+func builtinListToVector(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_QEXPR {
+		return lvalErr("Function 'list->vector' must be given a single q expression")
+	}
+
+	return lvalVector(lvecFrom(a.Cell[0].Cell))
+}
+
+// (vector->list [a b ...]) makes a q expression holding the elements of a vector
+func builtinVectorToList(e *LEnv, a *LVal) *LVal {
+	if len(a.Cell) != 1 || a.Cell[0].Type != LVAL_VECTOR {
+		return lvalErr("Function 'vector->list' must be given a single vector")
+	}
+
+	v := lvalQexpr()
+	v.Cell = a.Cell[0].Vec.items()
+	return v
+}
@@ -0,0 +1,21 @@
+package lispy
+
+import (
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	runLispyTests(t, []lispyTest{
+		{`(def {v} [1 (+ 1 1) 3]) (list v (nth v 1) (assoc v 0 10 3 4) (count v) (slice v 1 3) (push v 4 5) v)`, "{[1 2 3] 2 [10 2 3 4] 3 [2 3] [1 2 3 4 5] [1 2 3]}"},
+		{`(list (vector->list [1 2]) (list->vector {a b}) (== [1 2] [1 2.0]) (== [1 2] {1 2}))`, "{{1 2} [a b] true false}"},
+		{`(list (nth {a b c} 2) (slice {a b c} 0 2) (count {}))`, "{c {a b} 0}"},
+		{`(list [] [#_1] (count []) (push [] 1) (slice [1 2] 1 1))`, "{[] [] 0 [1] []}"},
+		{`(def {build} (fn {n v} {if (== n 0) {v} {build (- n 1) (push v n)}})) (def {big} (build 5000 []))
+		  (list (count big) (nth big 0) (nth big 4999) (nth (assoc big 1234 "x") 1234) (nth big 1234) (count (slice big 100 4100)))`, `{5000 5000 1 "x" 3766 4000}`},
+		{`(nth [1 2 3] 3)`, "error: Function 'nth' was given an index out of range"},
+		{`(nth [1 2 3] -1)`, "error: Function 'nth' was given an index out of range"},
+		{`(slice [1 2 3] 2 1)`, "error: Function 'slice' must be given an end that isn't before its start"},
+		{`(push {1} 2)`, "error: Function 'push' must be given a vector as its first argument"},
+		{`(count 1)`, "error: Function 'count' must be given a vector, q expression or map"},
+	})
+}
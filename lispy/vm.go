@@ -0,0 +1,612 @@
+package lispy
+
+import (
+	"reflect"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////
+// This file contains the vm that runs the bytecode made in compile.go. Locals live in      //
+// slots instead of environments, and calls between compiled functions push frames onto a  //
+// stack instead of recursing in go. Builtins that need an environment get one built from  //
+// the slots, so they behave the same as they do under lvalEval.                           //
+//////////////////////////////////////////////////////////////////////////////////////////////
+
+// A compiled function along with the frame it closes over. Partially applied functions keep
+// the arguments they have been given so far in bound.
+type vmClosure struct {
+	proto *vmProto
+	env   *vmEnv
+	bound []*LVal
+}
+
+// The slots of one call. The outermost one belongs to a top level expression, has no slots,
+// and links to the environment that expression was run in.
+type vmEnv struct {
+	slots []*LVal
+	proto *vmProto
+	par   *vmEnv
+	outer *LEnv
+}
+
+// A frame and its slots in one allocation, for the usual case of a function with only a few locals
+type vmSmallEnv struct {
+	env vmEnv
+	buf [4]*LVal
+}
+
+type vmFrame struct {
+	proto *vmProto
+	env   *vmEnv
+	pc    int
+	base  int
+
+	// The s expression that called this frame, which errors it returns get a stack trace entry
+	// for. Nil for the frame vmRun started with, since whoever called vmRun takes care of that.
+	call *LVal
+
+	// For code run in an environment built from the slots of back, that environment, so anything
+	// the code binds can be copied back into them once it is done
+	lenv *LEnv
+	back *vmEnv
+}
+
+// What a global symbol was last found to be, kept for as long as the global environment it was
+// found in hasn't changed, so the vm doesn't have to look it up every time
+type vmCache struct {
+	env     *LEnv
+	version int
+	value   *LVal
+}
+
+// Look sym up starting from env the same way lenvGet does
+func (c *vmCache) get(env *LEnv, sym *LVal) *LVal {
+	if c.env == env && c.version == env.version {
+		return c.value
+	}
+
+	x := lenvGet(env, sym)
+
+	//Only the global environment can be trusted to bump its version, since an environment with
+	//parents can see changes made further up the chain without its own version changing
+	if env.Par == nil && x.Type != LVAL_ERR {
+		*c = vmCache{env: env, version: env.version, value: x}
+	}
+
+	return x
+}
+
+// How many bodies lvalEvalBody keeps compiled before starting over, since code built while the
+// program runs would otherwise keep adding to them
+const vmMaxBodies = 1024
+
+// Builtins that never look at the environment they are called in, so the vm doesn't need to
+// build one for them. Anything not in here gets the locals of the calling frame.
+var vmPureBuiltins = map[uintptr]bool{}
+
+func init() {
+	pure := []LBuiltin{
+		builtinList, builtinHead, builtinTail, builtinJoin, builtinPrint,
+		builtinAdd, builtinSubtract, builtinMultiply, builtinDivide,
+		builtinLessThan, builtinGreaterThan, builtinLessThanOrEqualTo, builtinGreaterThanOrEqualTo, builtinEq,
+		builtinQuote, builtinUnquote, builtinUnquoteSplicing,
+		builtinThrow, builtinSignal, builtinInvokeRestart,
+		builtinErrorMessage, builtinErrorType, builtinErrorValue,
+		builtinHashMap, builtinGet, builtinAssoc, builtinDissoc, builtinKeys, builtinVals, builtinContains, builtinMerge,
+		builtinVector, builtinNth, builtinCount, builtinSlice, builtinPush, builtinListToVector, builtinVectorToList,
+		builtinStrLen, builtinSubstr, builtinStrIndex, builtinUpper, builtinLower, builtinSplit, builtinJoinStr, builtinTrim, builtinReplace,
+		builtinCharToInt, builtinIntToChar, builtinStringToChars, builtinCharsToString,
+	}
+
+	//Every character predicate shares the code of the closure made by builtinCharPredicate
+	pure = append(pure, builtinCharPredicate("", nil))
+
+	//Every go function shares the code of the closure made by lvalGoFunc
+	goFunc, _ := lvalGoFunc("", func() {})
+	pure = append(pure, goFunc)
+
+	for i := 0; i < len(pure); i++ {
+		vmPureBuiltins[reflect.ValueOf(pure[i]).Pointer()] = true
+	}
+}
+
+// Compile and run a top level expression in e
+func vmEval(e *LEnv, v *LVal) *LVal {
+	proto := vmCompile(e, v, false)
+	return vmRun(proto, &vmEnv{proto: proto, outer: e})
+}
+
+// Evaluate the q expression body in e with whichever of the vm and lvalEval the interpreter e
+// belongs to is running. For builtins like try that have to evaluate code themselves, since they
+// can't hand it back to be run in place of the call.
+func lvalEvalBody(e *LEnv, body *LVal) *LVal {
+	root := lenvRoot(e)
+	if !root.vm {
+		return lvalEval(e, lvalAs(body, LVAL_SEXPR))
+	}
+
+	//A try in a loop is given the same body every time round, so it is only compiled once. The
+	//guards in the code take care of it being run somewhere other than where it was compiled.
+	proto := root.bodies[body]
+	if proto == nil {
+		if len(root.bodies) >= vmMaxBodies {
+			root.bodies = nil
+		}
+		if root.bodies == nil {
+			root.bodies = make(map[*LVal]*vmProto)
+		}
+
+		proto = vmCompile(e, lvalAs(body, LVAL_SEXPR), false)
+		root.bodies[body] = proto
+	}
+
+	return vmRun(proto, &vmEnv{proto: proto, outer: e})
+}
+
+// Turn a compiled closure into a function lval
+func vmFunc(c *vmClosure, formals *LVal) *LVal {
+	x := &LVal{Type: LVAL_FUN, Formals: formals, Body: c.proto.body, Code: c}
+
+	//Anything other than the vm calls it like a builtin
+	x.Builtin = func(e *LEnv, a *LVal) *LVal {
+		env, result := vmBind(x, a.Cell)
+		if env == nil {
+			return result
+		}
+
+		return vmRun(c.proto, env)
+	}
+
+	return x
+}
+
+// Bind arguments to a compiled function's formals. Gives back the new frame's slots, or
+// a partially applied function or error if the call can't go ahead.
+func vmBind(f *LVal, args []*LVal) (*vmEnv, *LVal) {
+	c := f.Code
+	p := c.proto
+
+	all := args
+	if len(c.bound) > 0 {
+		all = make([]*LVal, 0, len(c.bound)+len(args))
+		all = append(all, c.bound...)
+		all = append(all, args...)
+	}
+
+	if len(all) > p.nformals && !p.variadic {
+		return nil, lvalErr("Function passed too many arguments")
+	}
+
+	//Not everything was bound, so hand back a function waiting on the rest
+	if len(all) < p.nformals {
+		bound := make([]*LVal, len(all))
+		copy(bound, all)
+
+		return nil, vmFunc(&vmClosure{proto: p, env: c.env, bound: bound}, lvalAs(&LVal{Cell: p.formals.Cell[len(all):]}, LVAL_QEXPR))
+	}
+
+	var env *vmEnv
+	if len(p.names) <= 4 {
+		small := &vmSmallEnv{}
+		env = &small.env
+		env.slots = small.buf[:len(p.names)]
+	} else {
+		env = &vmEnv{slots: make([]*LVal, len(p.names))}
+	}
+
+	env.proto = p
+	env.par = c.env
+	env.outer = c.env.outer
+	copy(env.slots, all[:p.nformals])
+
+	if p.variadic {
+		rest := lvalQexpr()
+		rest.Cell = append(rest.Cell, all[p.nformals:]...)
+		env.slots[p.nformals] = rest
+	}
+
+	return env, nil
+}
+
+// Bind the arguments of a tail call straight into the slots of env, which is the frame making
+// it, if f is the function that frame is running and nothing could have kept hold of the slots.
+// Reports false if the call needs a frame of its own.
+func (env *vmEnv) rebind(f *LVal, args []*LVal) bool {
+	c := f.Code
+	p := c.proto
+
+	//Closures made by the function's body are the only thing that can keep its slots around
+	if env.proto != p || env.par != c.env || len(p.protos) > 0 || len(c.bound) > 0 || p.variadic || len(args) != p.nformals {
+		return false
+	}
+
+	copy(env.slots, args)
+	for i := len(args); i < len(env.slots); i++ {
+		env.slots[i] = nil
+	}
+
+	return true
+}
+
+// Build an environment holding the locals of env so a builtin can use it
+func (env *vmEnv) lenv() *LEnv {
+	var par *LEnv
+	if env.par != nil {
+		par = env.par.lenv()
+	} else {
+		par = env.outer
+	}
+
+	if len(env.slots) == 0 {
+		return par
+	}
+
+	x := lenvNew(par)
+	for i := 0; i < len(env.slots); i++ {
+		if env.slots[i] != nil {
+			x.Vals[env.proto.names[i]] = env.slots[i]
+		}
+	}
+
+	return x
+}
+
+// Copy anything a builtin bound in an environment made by lenv back into the slots
+func (env *vmEnv) writeBack(e *LEnv) {
+	if len(env.slots) == 0 {
+		return
+	}
+
+	for i := 0; i < len(env.slots); i++ {
+		if v, ok := e.Vals[env.proto.names[i]]; ok {
+			env.slots[i] = v
+		}
+	}
+}
+
+// Look a symbol up by name starting from env, for locals that haven't been bound with = yet
+func (env *vmEnv) lookup(sym *LVal) *LVal {
+	for ; env != nil; env = env.par {
+		for i := len(env.slots) - 1; i >= 0; i-- {
+			if env.slots[i] != nil && env.proto.names[i] == sym.Sym {
+				return env.slots[i]
+			}
+		}
+
+		if env.par == nil {
+			return lenvGet(env.outer, sym)
+		}
+	}
+
+	return lvalErr("Unbound Symbol")
+}
+
+// Call something that isn't a compiled function, the same way lvalEvalSexpr would. Code handed
+// back to run in place of the call is left for vmRun, along with the environment built from the
+// slots of env if the builtin needed one.
+func vmCallOther(env *vmEnv, f *LVal, args []*LVal) (*LVal, *LEnv) {
+	a := lvalSexpr()
+	a.Cell = make([]*LVal, len(args))
+	copy(a.Cell, args)
+
+	if f.Builtin == nil {
+		return lvalCall(env.outer, f, a), nil
+	}
+
+	if len(env.slots) == 0 && env.par == nil {
+		return f.Builtin(env.outer, a), nil
+	}
+
+	if vmPureBuiltins[reflect.ValueOf(f.Builtin).Pointer()] {
+		return f.Builtin(env.outer, a), nil
+	}
+
+	e := env.lenv()
+	x := f.Builtin(e, a)
+	env.writeBack(e)
+
+	return x, e
+}
+
+// Check the function and arguments of a call for errors the way lvalEvalSexpr does. Gives back
+// the result of the call if it is already decided.
+func vmCheckCall(f *LVal, args []*LVal) *LVal {
+	if f.Type == LVAL_ERR {
+		return f
+	}
+
+	for i := 0; i < len(args); i++ {
+		if args[i].Type == LVAL_ERR {
+			return args[i]
+		}
+	}
+
+	if len(args) == 0 {
+		return f
+	}
+
+	if f.Type != LVAL_FUN {
+		return lvalErr("First Element is not a function")
+	}
+
+	return nil
+}
+
+// Run a compiled function until its frame returns
+func vmRun(proto *vmProto, env *vmEnv) *LVal {
+	stack := make([]*LVal, 0, 32)
+	frames := []vmFrame{{proto: proto, env: env}}
+
+	for {
+		fr := &frames[len(frames)-1]
+		code := fr.proto.code
+		op := code[fr.pc]
+
+		switch op {
+		case opConst:
+			stack = append(stack, fr.proto.consts[code[fr.pc+1]])
+			fr.pc += 2
+
+		case opLocal:
+			e := fr.env
+			for d := code[fr.pc+1]; d > 0; d-- {
+				e = e.par
+			}
+
+			x := e.slots[code[fr.pc+2]]
+			if x == nil {
+				//The slot is for something bound with = that hasn't happened yet
+				x = e.lookup(fr.proto.consts[code[fr.pc+3]])
+			}
+
+			stack = append(stack, x)
+			fr.pc += 4
+
+		case opGlobal:
+			stack = append(stack, fr.proto.caches[code[fr.pc+2]].get(fr.env.outer, fr.proto.consts[code[fr.pc+1]]))
+			fr.pc += 3
+
+		case opClosure:
+			p := fr.proto.protos[code[fr.pc+1]]
+			stack = append(stack, vmFunc(&vmClosure{proto: p, env: fr.env}, p.formals))
+			fr.pc += 2
+
+		case opMacroCheck:
+			f := stack[len(stack)-1]
+			if f.Type == LVAL_FUN && f.Macro {
+				//A macro that wasn't defined yet when this was compiled, so compile the form again now that it is
+				form := fr.proto.consts[code[fr.pc+1]]
+				tail := code[fr.pc+2] == 1
+				fr.pc = code[fr.pc+3]
+
+				stack = stack[:len(stack)-1]
+				stack, frames = vmEnter(stack, frames, fr.env.lenv(), form, fr.env, tail, nil)
+			} else {
+				fr.pc += 4
+			}
+
+		case opGuard:
+			if fr.proto.caches[code[fr.pc+6]].get(fr.env.outer, fr.proto.consts[code[fr.pc+1]]) == fr.proto.consts[code[fr.pc+2]] {
+				fr.pc += 7
+			} else {
+				//The symbol has been rebound since this was compiled, so compile the form again as it is now
+				form := fr.proto.consts[code[fr.pc+3]]
+				tail := code[fr.pc+4] == 1
+				fr.pc = code[fr.pc+5]
+
+				stack, frames = vmEnter(stack, frames, fr.env.lenv(), form, fr.env, tail, nil)
+			}
+
+		case opCall, opTailCall:
+			argc := code[fr.pc+1]
+			form := fr.proto.consts[code[fr.pc+2]]
+			fr.pc += 3
+
+			f := stack[len(stack)-argc-1]
+			args := stack[len(stack)-argc:]
+
+			result := vmCheckCall(f, args)
+			if result == nil && f.Code != nil {
+				if op == opTailCall && fr.lenv == nil && fr.env.rebind(f, args) {
+					//A loop calling itself again, so carry on in the same frame and slots
+					stack = stack[:fr.base]
+					fr.pc, fr.call = 0, form
+					continue
+				}
+
+				var callEnv *vmEnv
+				callEnv, result = vmBind(f, args)
+
+				if callEnv != nil {
+					stack = stack[:len(stack)-argc-1]
+
+					if op == opTailCall {
+						//Reuse the current frame rather than stacking another on top of it
+						if fr.lenv != nil {
+							fr.back.writeBack(fr.lenv)
+						}
+
+						stack = stack[:fr.base]
+						*fr = vmFrame{proto: f.Code.proto, env: callEnv, base: fr.base, call: form}
+					} else {
+						frames = append(frames, vmFrame{proto: f.Code.proto, env: callEnv, base: len(stack), call: form})
+					}
+					continue
+				}
+
+				result = vmErrFrame(result, form)
+			} else if result == nil {
+				var e *LEnv
+				result, e = vmCallOther(fr.env, f, args)
+
+				if result.Type == LVAL_TAIL {
+					stack = stack[:len(stack)-argc-1]
+
+					//The body of a lambda the tree walker made is a call of its own, while code handed
+					//back by a builtin like eval carries on the call it was in
+					var call *LVal
+					if f.Builtin == nil {
+						call = form
+					}
+
+					var back *vmEnv
+					if e != nil {
+						back = fr.env
+					}
+
+					stack, frames = vmEnter(stack, frames, result.Env, result.Body, back, op == opTailCall, call)
+					continue
+				}
+
+				result = vmErrFrame(result, form)
+			}
+
+			stack = stack[:len(stack)-argc-1]
+			stack = append(stack, result)
+
+			if op == opTailCall {
+				stack, frames = vmReturn(stack, frames)
+				if len(frames) == 0 {
+					return stack[0]
+				}
+			}
+
+		case opBranch:
+			cond := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			switch {
+			case cond.Type == LVAL_ERR:
+				stack = append(stack, cond)
+				fr.pc = code[fr.pc+2]
+			case !lvalTruthy(cond):
+				fr.pc = code[fr.pc+1]
+			default:
+				fr.pc += 3
+			}
+
+		case opJump:
+			fr.pc = code[fr.pc+1]
+
+		case opReturn:
+			stack, frames = vmReturn(stack, frames)
+			if len(frames) == 0 {
+				return stack[0]
+			}
+
+		case opArith:
+			argc := code[fr.pc+2]
+			args := stack[len(stack)-argc:]
+
+			x := vmCheckArgs(args)
+			if x == nil {
+				if argc == 2 && args[0].Type == LVAL_INT && args[1].Type == LVAL_INT {
+					x = lvalIntOp(vmArithNames[code[fr.pc+1]], args[0].Int, args[1].Int)
+				} else {
+					x = lvalArith(args, vmArithNames[code[fr.pc+1]])
+				}
+
+				x = vmErrFrame(x, fr.proto.consts[code[fr.pc+3]])
+			}
+
+			stack = append(stack[:len(stack)-argc], x)
+			fr.pc += 4
+
+		case opCompare:
+			args := stack[len(stack)-2:]
+
+			var x *LVal
+			if args[0].Type == LVAL_INT && args[1].Type == LVAL_INT {
+				x = vmCompare2(code[fr.pc+1], args[0].Int, args[1].Int)
+			} else if x = vmCheckArgs(args); x == nil {
+				x = vmErrFrame(lvalCompare(args, vmCompareNames[code[fr.pc+1]]), fr.proto.consts[code[fr.pc+2]])
+			}
+
+			stack = append(stack[:len(stack)-2], x)
+			fr.pc += 3
+		}
+	}
+}
+
+// Compile v and run it in e as a frame of its own, on top of the current one or in place of it
+// for a tail call. back is where e came from if it was built from slots. A call of nil carries
+// on the call of the frame being replaced.
+func vmEnter(stack []*LVal, frames []vmFrame, e *LEnv, v *LVal, back *vmEnv, tail bool, call *LVal) ([]*LVal, []vmFrame) {
+	proto := vmCompile(e, v, true)
+	x := vmFrame{proto: proto, env: &vmEnv{proto: proto, outer: e}, base: len(stack), call: call}
+
+	if back != nil {
+		x.lenv, x.back = e, back
+	}
+
+	if !tail {
+		return stack, append(frames, x)
+	}
+
+	fr := &frames[len(frames)-1]
+	if fr.lenv != nil {
+		fr.back.writeBack(fr.lenv)
+	}
+	if call == nil {
+		x.call = fr.call
+	}
+
+	x.base = fr.base
+	*fr = x
+
+	return stack[:fr.base], frames
+}
+
+// Pop the current frame, leaving its result on top of the caller's stack
+func vmReturn(stack []*LVal, frames []vmFrame) ([]*LVal, []vmFrame) {
+	fr := frames[len(frames)-1]
+	result := stack[len(stack)-1]
+
+	if fr.call != nil {
+		result = vmErrFrame(result, fr.call)
+	}
+	if fr.lenv != nil {
+		fr.back.writeBack(fr.lenv)
+	}
+
+	stack = append(stack[:fr.base], result)
+	return stack, frames[:len(frames)-1]
+}
+
+// Add the call made by form to the trace of x if it is an error
+func vmErrFrame(x *LVal, form *LVal) *LVal {
+	if x.Type != LVAL_ERR {
+		return x
+	}
+
+	return lvalErrFrame(x, lvalCallName(form), form.Pos)
+}
+
+// Give back the first error among the arguments to a builtin, if there is one
+func vmCheckArgs(args []*LVal) *LVal {
+	for i := 0; i < len(args); i++ {
+		if args[i].Type == LVAL_ERR {
+			return args[i]
+		}
+	}
+
+	return nil
+}
+
+// The common case of comparing two integers, without going through lvalCompare
+func vmCompare2(op int, x int64, y int64) *LVal {
+	var b bool
+	switch op {
+	case 0:
+		b = x < y
+	case 1:
+		b = x > y
+	case 2:
+		b = x <= y
+	case 3:
+		b = x >= y
+	default:
+		b = x == y
+	}
+
+	return lvalBool(b)
+}
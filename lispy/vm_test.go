@@ -0,0 +1,238 @@
+package lispy
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// Programs that should give the same result whether they are compiled or walked
+var vmCrossCheckPrograms = []string{
+	`(+ 1 2 3)`,
+	`(- 5)`,
+	`(/ 1 0)`,
+	`(+ 1 {2})`,
+	`(< 1 2)`,
+	`(== {1 2} {1 2})`,
+	`()`,
+	`(5)`,
+	`(1 2)`,
+	`undefined`,
+	`(head {1 2 3})`,
+	`(tail {1 2 3})`,
+	`(join {1} {2 3})`,
+	`(eval {+ 1 2})`,
+	`(if (< 1 2) {+ 1 1} {+ 2 2})`,
+	`(if {1} {1} {2})`,
+	`(if (+ 1 {}) {1} {2})`,
+	`(def {x} 10) (+ x 1)`,
+	`(def {f} (fn {x y} {+ x y})) (f 1 2)`,
+	`(def {f} (fn {x y} {+ x y})) (f 1 2 3)`,
+	`(def {f} (fn {x y} {+ x y})) (def {g} (f 1)) (g 10)`,
+	`(def {f} (fn {x y} {+ x y})) (f 1)`,
+	`(def {f} (fn {a & r} {join {a} r})) (f 1 2 3)`,
+	`(def {f} (fn {a & r} {r})) (f 1)`,
+	`(def {f} (fn {_} {+ 1 2})) (f {})`,
+	`(def {f} (fn {x} {x})) (f head)`,
+	`(def {make-adder} (fn {n} {fn {x} {+ x n}})) (def {n} 100) ((make-adder 5) 1)`,
+	`(def {x} 7) (def {f} (fn {y} {+ x y})) (def {g} (fn {x} {f 1})) (g 1000)`,
+	`(def {fact} (fn {n} {if (== n 0) {1} {* n (fact (- n 1))}})) (fact 10)`,
+	`(def {f} (fn {x} {eval {+ x 1}})) (f 41)`,
+	`(def {f} (fn {x} {(= {y} (* x 2)) (+ y 1)})) (f 3)`,
+	`(def {f} (fn {c} {if c {1} {2}})) (f {})`,
+	`(def {f} (fn {x} {fn {y} {x}})) (((f 3) 4))`,
+	`(def {f} (fn {x} {x})) (f head)`,
+	`(defmacro {unless} {c a b} {` + "`" + `(if ,c {,b} {,a})}) (unless (> 1 2) 1 2)`,
+	`(defmacro {unless} {c a b} {` + "`" + `(if ,c {,b} {,a})}) (def {f} (fn {x} {unless x 1 2})) (f 0)`,
+	`(def {f} (fn {x} {later x})) (defmacro {later} {x} {x}) (f 9)`,
+	`(def {xs} {1 2 3}) ` + "`" + `(a ,(+ 1 1) ,@xs)`,
+	`(math/sqrt 16)`,
+	`(def {f} (fn {s} {strings/upper s})) (f "abc")`,
+	`(def {if} (fn {a b c} {b})) (if 1 2 3)`,
+	"(def {f} (fn {x} {+ x {}}))\n(def {g} (fn {x} {* 2 (f x)}))\n(g 1)",
+	"(def {f} (fn {x} {if (== x 0) {undefined} {f (- x 1)}}))\n(f 3)",
+	"(def {f} (fn {x} {if x {1} {2}}))\n(+ 1 (f {}))",
+	"(def {f} (fn {x} {/ x 0}))\n(def {g} (fn {x} {(f x)}))\n(g 1)",
+	"(def {f} (fn {x y} {x}))\n(f 1 2 3)",
+	"(defmacro {m} {a b} {a})\n(def {f} (fn {_} {m 1}))\n(f {})",
+	`(try {throw "bad" "oops" 3} {catch {e} {list (error-type e) (error-message e) (error-value e)}})`,
+	`(def {f} (fn {x} {try {/ 1 x} {catch "bad" {e} {0}} {catch {e} {(= {y} 2) (+ x y)}}})) (list (f 1) (f 0))`,
+	`(def {f} (fn {x} {try {throw "bad" "oops"} {catch {e} {throw e}}})) (f 1)`,
+	`(try {+ 1 {}} {finally {throw "cleanup" "failed"}})`,
+	`(def {p} (fn {r} {restart-case {if (< r 0) {throw "bad" "negative" r} {r}} {"skip" {} {0}} {"use" {v} {v}}}))
+	 (list (handler-bind "bad" (fn {c} {invoke-restart "use" 9}) {list (p 1) (p (- 0 1))})
+	       (handler-bind (fn {c} {invoke-restart "skip"}) {p (- 0 1)})
+	       (handler-bind (fn {c} {invoke-restart "skip"}) {try {p (- 0 1)} {catch {e} {error-message e}}}))`,
+	`(def {f} (fn {x} {(= {y} 1) (restart-case {+ y (invoke-restart "r" x)} {"r" {z} {* z 2}})})) (f 5)`,
+	`(handler-bind (fn {c} {error-value c}) {signal "note" "hello" 1})`,
+	`(restart-case {throw "bad" "unhandled"} {"r" {} {1}})`,
+	`(list (* 99999999999 99999999999) (/ 1 3) (+ 1 2.0) (== 1 1.0) 42.0 (/ 6 3))`,
+	`(list (+ 9223372036854775807 1) (- (* 9223372036854775807 2) 9223372036854775807) (- (- 0 9223372036854775807 1)))`,
+	`(list (+ (/ 1 3) (/ 2 3)) (* (/ 2 3) 1.5) (< (/ 1 3) 0.34) (> 100000000000000000000 (/ 1 2)))`,
+	`(def {fact} (fn {n} {if (== n 0) {1} {* n (fact (- n 1))}})) (/ (fact 30) (fact 28))`,
+	`(/ (/ 1 2) 0)`,
+	`(< 1 "a")`,
+	`(list 3+4i (+ 3+4i 1) (* 2i 2i) (/ 1 2i) (- 3-4.5i) (== 3+0i 3) (+ (/ 1 2) 1.5i))`,
+	`(list (cmath/abs 3+4i) (cmath/sqrt (- 0 4)) (cmath/exp 0) (cmath/conj 1+2i))`,
+	`(< 1i 2)`,
+	`(list (if 2 {1} {2}) (if 0 {1} {2}) (if nil {1} {2}) (if false {1} {2}) (if {} {1} {2}))`,
+	`(list (< 1 2) (== nil nil) (== false nil) (== true (> 2 1)) {true false nil})`,
+	`(def {f} (fn {x} {if x {x} {nil}})) (list (f false) (f true) (f 0))`,
+	`(def {nil} 1)`,
+	`(def {m} #{"b" 2 "a" (+ 1 0)}) (list m (get m "a") (get m "z") (get m "z" 0) (assoc m "c" 3) (dissoc m "a") (keys m) (vals m))`,
+	`(list (contains? #{1 2} 1.0) (merge #{"a" 1} #{"a" 10 "b" 2}) (== #{"a" 1 "b" 2} #{"b" 2 "a" 1.0}) (get #{#{1 2} "m"} #{1 2}))`,
+	`(def {f} (fn {k} {#{k (* k 2)}})) (f 4)`,
+	`#{1 2 3}`,
+	`(def {v} [1 (+ 1 1) 3]) (list v (nth v 1) (assoc v 0 10 3 4) (count v) (slice v 1 3) (push v 4 5) (vector->list v) (list->vector {a b}))`,
+	`(list (== [1 2] [1 2.0]) (nth {a b c} 2) (slice {a b c} 0 2) (count #{1 2}) (get #{[1 2] 3} [1 2]))`,
+	`(def {build} (fn {n v} {if (== n 0) {v} {build (- n 1) (push v n)}})) (def {big} (build 5000 [])) (list (count big) (nth big 4999) (nth (assoc big 1234 "x") 1234) (nth big 1234))`,
+	`(nth [1 2 3] 3)`,
+	`(list "tab\there\n \"q\" \\ \u{e9}" (str-len "héllo") (substr "héllo wörld" 1 8) (str-index "héllo wörld" "wö") (str-index "abc" "z"))`,
+	`(list (upper "straße") (lower "ÉCOLE") (split "a,b,,c" ",") (join-str ["x" "y"] ", ") (trim "  hi \n") (replace "aXbXc" "X" "--"))`,
+	`(substr "abc" 2 1)`,
+	`(list #\a #\newline #\x3bb #\( (char->int #\A) (int->char 955) (string->chars "héllo") (chars->string [#\h #\i]))`,
+	`(list (char-letter? #\é) (char-digit? #\7) (char-upper? #\a) (char-space? #\tab) (char-punct? #\!) (== #\a #\a) (get #{#\a 1} #\a))`,
+	`(chars->string {#\a "b"})`,
+	"; a comment (\n(list 1 #| a #| nested |# ) |# 2 #_(3 4) [5 #_6] 7) ; trailing",
+	"#| spans\n lines |#\n  (head {})",
+	`(list -5 +5 -1.5 .5 1e3 -2.5E-2 1_000_000 0xff -0x_1F 0b1010 0o17 -3-4i 1e1+2i (- 5 -3) (* -1 -99999999999999999999))`,
+	"(list 'a '(1 (+ 1 1)) '5 (quote {x}) `(a ,(+ 1 1) 'b))",
+	`(def {f} (fn {x} {+ x 1})) (def {+} -) (f 1)`,
+	`(def {f} (fn {x} {if x {1} {2}})) (def {if} (fn {c a b} {a})) (f false)`,
+	`(defmacro {m} {x} {x}) (def {f} (fn {_} {m 1})) (defmacro {m} {x} {99}) (f {})`,
+	`(def {f} (fn {x} {< x 1})) (def {<} (fn {a b} {list a b})) (f 5)`,
+	`(def {f} (fn {x} {fn {y} {+ x y}})) (def {fn} list) (f 1)`,
+	`(def {f} (fn {n} {if (== n 0) {0} {eval {f (- n 1)}}})) (f 10)`,
+	`(def {f} (fn {x} {eval {+ x {}}})) (f 1)`,
+	`(def {f} (fn {x} {+ 1 (eval {undefined})})) (f 1)`,
+	`(def {f} (fn {x} {(eval {= {y} (* x 2)}) (+ y 1)})) (f 3)`,
+	`(def {f} (fn {x} {m x})) (defmacro {m} {x} {+ x 1}) (def {g} (fn {x} {(= {y} 2) (f (+ x y))})) (g 1)`,
+	`(def {k} 1) (def {f} (fn {x} {k})) (def {a} (f 0)) (def {k} 2) (list a (f 0))`,
+	`(def {mk} (fn {n fs} {if (== n 0) {fs} {mk (- n 1) (join fs (list (fn {_} {n})))}})) (def {fs} (mk 3 {})) (list ((eval (head fs)) 0) ((eval (head (tail (tail fs)))) 0))`,
+	`(def {f} (fn {n acc} {if (== n 0) {acc} {f (- n 1) (join acc (list n))}})) (list (f 3 {}) (f 2 {}))`,
+	`(def {f} (fn {x} {if (try {= {y} (* x 2)}) {y} {y}})) (list (f 1) (f 2))`,
+	`(def {f} (fn {x} {restart-case {invoke-restart "r" x} {"r" {v} {+ v 1}}})) (list (f 1) (f 2))`,
+}
+
+func vmCrossCheckEval(t *testing.T, src string, treeWalk bool) string {
+	in := New()
+	in.TreeWalk = treeWalk
+
+	v, err := in.EvalString(src)
+	if err != nil {
+		lerr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("%s: %v", src, err)
+		}
+		return "error: " + lerr.Error() + lerr.StackTrace()
+	}
+
+	return Sprint(v)
+}
+
+func TestVMMatchesTreeWalker(t *testing.T) {
+	for _, src := range vmCrossCheckPrograms {
+		want := vmCrossCheckEval(t, src, true)
+		got := vmCrossCheckEval(t, src, false)
+
+		if got != want {
+			t.Errorf("%s: vm gave %s, tree walker gave %s", src, got, want)
+		}
+	}
+}
+
+// A program along with what it should print, or the start of the error it should give
+type lispyTest struct {
+	src  string
+	want string
+}
+
+// Run each of the tests on both the vm and the tree walker. Errors are compared without their
+// stack traces, which are checked against each other by TestVMMatchesTreeWalker.
+func runLispyTests(t *testing.T, tests []lispyTest) {
+	for _, test := range tests {
+		for _, treeWalk := range []bool{true, false} {
+			got := vmCrossCheckEval(t, test.src, treeWalk)
+
+			if strings.HasPrefix(test.want, "error: ") {
+				got = strings.SplitN(got, "\n", 2)[0]
+			}
+			if got != test.want {
+				t.Errorf("%s (tree walk %v): got %s, want %s", test.src, treeWalk, got, test.want)
+			}
+		}
+	}
+}
+
+func TestVMTailCallsDontGrowTheStack(t *testing.T) {
+	//Keep the go stack small enough that recursing for each call would overflow it
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	in := New()
+
+	programs := []string{
+		`(def {loop} (fn {n} {if (== n 0) {0} {loop (- n 1)}})) (loop 100000)`,
+		`(def {f} (fn {n} {if (== n 0) {0} {eval {f (- n 1)}}})) (f 100000)`,
+		`(def {f} (fn {n} {if (== n 0) {0} {m (- n 1)}})) (defmacro {m} {x} {join {f} (list x)}) (f 100000)`,
+		`(def {f} (fn {n} {if (== n 0) {0} {f (- n 1)}})) (def {old-if} if) (defmacro {if} {c a b} {join {old-if} (list c a b)}) (f 100000)`,
+	}
+
+	for _, src := range programs {
+		v, err := in.EvalString(src)
+		if err != nil || Sprint(v) != "0" {
+			t.Fatalf("%s gave %v, %v", src, v, err)
+		}
+	}
+}
+
+func TestTreeWalkTailCallsDontGrowTheStack(t *testing.T) {
+	//The tree walker bounces tail calls back out to lvalEvalCall instead of recursing
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	in := New()
+	in.TreeWalk = true
+
+	programs := []string{
+		`(def {loop} (fn {n} {if (== n 0) {0} {loop (- n 1)}})) (loop 100000)`,
+		`(def {f} (fn {n} {if (== n 0) {0} {eval {f (- n 1)}}})) (f 100000)`,
+		`(def {f} (fn {n} {if (== n 0) {0} {m (- n 1)}})) (defmacro {m} {x} {join {f} (list x)}) (f 100000)`,
+		`(def {even} (fn {n} {if (== n 0) {0} {odd (- n 1)}})) (def {odd} (fn {n} {even (- n 1)})) (even 100000)`,
+	}
+
+	for _, src := range programs {
+		v, err := in.EvalString(src)
+		if err != nil || Sprint(v) != "0" {
+			t.Fatalf("%s gave %v, %v", src, v, err)
+		}
+	}
+}
+
+var vmBenchmarkPrograms = map[string]string{
+	"fib":  `(def {fib} (fn {n} {if (< n 2) {n} {+ (fib (- n 1)) (fib (- n 2))}}))`,
+	"loop": `(def {loop} (fn {n acc} {if (== n 0) {acc} {loop (- n 1) (+ acc n)}}))`,
+	"try": `(def {safe-double} (fn {n} {try {if (== n 0) {throw "zero"} {* n 2}} {catch {e} {0}}}))
+		(def {sum-double} (fn {n acc} {if (== n -1) {acc} {sum-double (- n 1) (+ acc (safe-double n))}}))`,
+}
+
+func benchmarkProgram(b *testing.B, name string, call string, treeWalk bool) {
+	in := New()
+	in.TreeWalk = treeWalk
+
+	if _, err := in.EvalString(vmBenchmarkPrograms[name]); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.EvalString(call); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibVM(b *testing.B)        { benchmarkProgram(b, "fib", "(fib 20)", false) }
+func BenchmarkFibTreeWalk(b *testing.B)  { benchmarkProgram(b, "fib", "(fib 20)", true) }
+func BenchmarkLoopVM(b *testing.B)       { benchmarkProgram(b, "loop", "(loop 100000 0)", false) }
+func BenchmarkLoopTreeWalk(b *testing.B) { benchmarkProgram(b, "loop", "(loop 100000 0)", true) }
+func BenchmarkTryVM(b *testing.B)        { benchmarkProgram(b, "try", "(sum-double 10000 0)", false) }
+func BenchmarkTryTreeWalk(b *testing.B)  { benchmarkProgram(b, "try", "(sum-double 10000 0)", true) }